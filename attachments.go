@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// attachment is a file added to the message being composed. Removed
+// lists the metadata scrubMetadata took out; Problem is set when the
+// file could not be cleaned and is sent as it is.
+type attachment struct {
+	Name    string
+	Data    []byte
+	Removed []string
+	Problem string
+}
+
+// rfc2231Chunk is the longest encoded piece put in one parameter
+// section, keeping Content-Disposition lines short.
+const rfc2231Chunk = 60
+
+func isAttrChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+// rfc2231Params formats the parameter key=value. Short printable ASCII
+// values are quoted; anything else, control characters included, is
+// percent-encoded as UTF-8 per RFC 2231 and split into numbered sections
+// when long.
+func rfc2231Params(key, value string) []string {
+	if isASCII(value) && len(value) <= rfc2231Chunk && checkLine(key, value) == nil {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		return []string{key + `="` + strings.ReplaceAll(value, `"`, `\"`) + `"`}
+	}
+	var chunks []string
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		piece := string(value[i])
+		if !isAttrChar(value[i]) {
+			piece = fmt.Sprintf("%%%02X", value[i])
+		}
+		if b.Len()+len(piece) > rfc2231Chunk {
+			chunks = append(chunks, b.String())
+			b.Reset()
+		}
+		b.WriteString(piece)
+	}
+	chunks = append(chunks, b.String())
+	if len(chunks) == 1 {
+		return []string{key + "*=utf-8''" + chunks[0]}
+	}
+	params := make([]string, len(chunks))
+	for i, c := range chunks {
+		if i == 0 {
+			c = "utf-8''" + c
+		}
+		params[i] = fmt.Sprintf("%s*%d*=%s", key, i, c)
+	}
+	return params
+}
+
+// attachmentPart renders a as a base64 MIME body part.
+func attachmentPart(a attachment) string {
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(a.Name)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if isASCII(a.Name) && !strings.ContainsAny(a.Name, "\"\\") && checkLine("name", a.Name) == nil {
+		contentType += ";\r\n name=\"" + a.Name + "\""
+	}
+	disposition := "attachment;\r\n " + strings.Join(rfc2231Params("filename", a.Name), ";\r\n ")
+	return "Content-Type: " + contentType + "\r\n" +
+		"Content-Disposition: " + disposition + "\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		encodeBase64Lines(a.Data)
+}
+
+// buildMixed turns a CRLF message into multipart/mixed with the text as
+// the first part and each attachment after it.
+func buildMixed(raw string, atts []attachment) (string, error) {
+	if len(atts) == 0 {
+		return raw, nil
+	}
+	header, body := splitMessage(raw)
+	fields := headerFields(header)
+	var inner []string
+	for _, f := range fields {
+		switch fieldName(f) {
+		case "content-type", "content-transfer-encoding", "content-disposition":
+			inner = append(inner, f)
+		}
+	}
+	text := prepareBody(joinMessage(strings.Join(inner, "\r\n"), body), false)
+	textHeader, textBody := splitMessage(text)
+	textFields := removeFields(headerFields(textHeader), "mime-version")
+
+	boundary, err := mimeBoundary()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("This is a multi-part message in MIME format.\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	b.WriteString(joinMessage(strings.Join(textFields, "\r\n"), textBody))
+	if !strings.HasSuffix(textBody, "\r\n") {
+		b.WriteString("\r\n")
+	}
+	for _, a := range atts {
+		b.WriteString("--" + boundary + "\r\n")
+		b.WriteString(attachmentPart(a))
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+
+	outer := removeFields(fields, "content-type", "content-transfer-encoding", "content-disposition", "mime-version")
+	outer = append(outer, "MIME-Version: 1.0", "Content-Type: multipart/mixed;\r\n boundary=\""+boundary+"\"")
+	return joinMessage(strings.Join(outer, "\r\n"), b.String()), nil
+}
+
+// encodedSize estimates how many bytes the attachments add once base64
+// encoded, headers included.
+func encodedSize(atts []attachment) int64 {
+	var n int64
+	for _, a := range atts {
+		b64 := int64(len(a.Data)+2) / 3 * 4
+		n += b64 + b64/76*2 + 2 + 300
+	}
+	return n
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// refreshAttachments updates the list and the size line, warning when
+// the last SIZE limit the SMTP server advertised would be exceeded.
+func (g *GUI) refreshAttachments() {
+	g.attachmentList.Refresh()
+	if len(g.attachments) == 0 {
+		g.attachmentSizeLabel.SetText("No attachments")
+		return
+	}
+	size := encodedSize(g.attachments) + int64(len(g.messageEnt.Text))
+	text := fmt.Sprintf("%d attachment(s), about %s encoded", len(g.attachments), formatSize(size))
+	// The limit is only known once a send has seen the server's SIZE
+	// extension, so say so rather than implying the size is fine.
+	switch limit := g.smtpSizeLimit.Load(); {
+	case limit <= 0:
+		text += " - server size limit not known until it advertises SIZE on a send"
+	case size > limit:
+		text += fmt.Sprintf(" - over the server's %s limit", formatSize(limit))
+	default:
+		text += fmt.Sprintf(" - server limit %s", formatSize(limit))
+	}
+	g.attachmentSizeLabel.SetText(text)
+}
+
+// refuseAttachments reports files in the attachment list when the message
+// goes by a route that cannot carry them, rather than dropping them.
+func (g *GUI) refuseAttachments(route string) bool {
+	if len(g.attachments) == 0 {
+		return false
+	}
+	dialog.ShowError(fmt.Errorf("Attachments cannot be sent %s; remove them first", route), g.window)
+	return true
+}
+
+func (g *GUI) addAttachment(uri fyne.URI) {
+	if err := checkLine("File name", uri.Name()); err != nil {
+		dialog.ShowError(fmt.Errorf("Cannot attach %q: %v", uri.Name(), err), g.window)
+		return
+	}
+	r, err := storage.Reader(uri)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to open %s: %v", uri.Name(), err), g.window)
+		return
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to read %s: %v", uri.Name(), err), g.window)
+		return
+	}
+	a := attachment{Name: uri.Name(), Data: data}
+	if cleaned, removed, err := scrubMetadata(data); err != nil {
+		a.Problem = err.Error()
+		dialog.ShowError(fmt.Errorf("Could not clean %s: %v\n\nIt will only be sent if \"Send uncleaned files\" is checked.", a.Name, err), g.window)
+	} else {
+		a.Data, a.Removed = cleaned, removed
+		if len(removed) > 0 {
+			dialog.ShowInformation("Metadata Removed", fmt.Sprintf("Removed from %s:\n\n%s", a.Name, strings.Join(removed, "\n")), g.window)
+		}
+	}
+	g.attachments = append(g.attachments, a)
+	g.refreshAttachments()
+}
+
+// uncleanAttachments returns an error naming the attachments that could
+// not be cleaned, unless the user chose to send them anyway.
+func (g *GUI) uncleanAttachments() error {
+	if g.sendUncleanCheck.Checked {
+		return nil
+	}
+	var names []string
+	for _, a := range g.attachments {
+		if a.Problem != "" {
+			names = append(names, a.Name+": "+a.Problem)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Attachments could not be cleaned of metadata:\n%s", strings.Join(names, "\n"))
+}
+
+// buildAttachmentArea is the attachment list on the Compose tab. Files
+// can also be dropped anywhere on the window. Metadata is stripped as
+// each file is added.
+func (g *GUI) buildAttachmentArea() fyne.CanvasObject {
+	selected := -1
+	g.attachmentList = widget.NewList(
+		func() int { return len(g.attachments) },
+		func() fyne.CanvasObject { return widget.NewLabel("attachment") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			a := g.attachments[id]
+			text := fmt.Sprintf("%s (%s)", a.Name, formatSize(int64(len(a.Data))))
+			switch {
+			case a.Problem != "":
+				text += " - not cleaned"
+			case len(a.Removed) > 0:
+				text += " - metadata removed"
+			}
+			o.(*widget.Label).SetText(text)
+		},
+	)
+	g.attachmentList.OnSelected = func(id widget.ListItemID) { selected = id }
+	g.attachmentSizeLabel = widget.NewLabel("No attachments")
+	g.sendUncleanCheck = widget.NewCheck("Send uncleaned files", nil)
+
+	attachButton := widget.NewButton("Attach File", func() {
+		dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			if r == nil {
+				return
+			}
+			uri := r.URI()
+			r.Close()
+			g.addAttachment(uri)
+		}, g.window)
+	})
+	removeButton := widget.NewButton("Remove", func() {
+		if selected < 0 || selected >= len(g.attachments) {
+			dialog.ShowError(fmt.Errorf("No attachment selected"), g.window)
+			return
+		}
+		g.attachments = append(g.attachments[:selected], g.attachments[selected+1:]...)
+		selected = -1
+		g.attachmentList.UnselectAll()
+		g.refreshAttachments()
+	})
+
+	g.window.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		for _, uri := range uris {
+			g.addAttachment(uri)
+		}
+	})
+
+	listScroll := container.NewVScroll(g.attachmentList)
+	listScroll.SetMinSize(fyne.NewSize(0, 60))
+	return container.NewBorder(
+		nil, nil, nil,
+		container.NewVBox(attachButton, removeButton, g.sendUncleanCheck),
+		container.NewBorder(nil, g.attachmentSizeLabel, nil, nil, listScroll),
+	)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the RFC 5322 limit on a line, excluding CRLF.
+const maxLineOctets = 998
+
+// bodyEncoding picks the Content-Transfer-Encoding for a CRLF body: 7bit
+// when it is plain ASCII, 8bit when the server takes 8BITMIME and the
+// UTF-8 text has no overlong lines, otherwise quoted-printable for mostly
+// ASCII text and base64 for the rest.
+func bodyEncoding(body string, eightBit bool) string {
+	safe := !strings.ContainsRune(body, 0)
+	for _, line := range strings.Split(body, "\r\n") {
+		if len(line) > maxLineOctets || strings.ContainsRune(line, '\r') || strings.ContainsRune(line, '\n') {
+			safe = false
+			break
+		}
+	}
+	nonASCII := 0
+	for i := 0; i < len(body); i++ {
+		if body[i] >= 0x80 {
+			nonASCII++
+		}
+	}
+	switch {
+	case safe && nonASCII == 0:
+		return "7bit"
+	case safe && eightBit && utf8.ValidString(body):
+		return "8bit"
+	case nonASCII*3 > len(body):
+		return "base64"
+	}
+	return "quoted-printable"
+}
+
+func encodeQuotedPrintable(body string) string {
+	var b strings.Builder
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(body))
+	w.Close()
+	return b.String()
+}
+
+func encodeBase64Lines(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.String()
+}
+
+// prepareBody makes the body of a CRLF message safe for transport. It
+// adds MIME-Version and a text/plain; charset=utf-8 Content-Type when they
+// are missing and encodes the body as bodyEncoding decides. Messages that
+// already name a transfer encoding, and multipart messages whose parts
+// carry their own, are returned unchanged.
+func prepareBody(raw string, eightBit bool) string {
+	header, body := splitMessage(raw)
+	fields := headerFields(header)
+	var contentType string
+	hasMIMEVersion := false
+	for _, f := range fields {
+		switch fieldName(f) {
+		case "content-transfer-encoding":
+			return raw
+		case "content-type":
+			contentType = fieldValue(f)
+		case "mime-version":
+			hasMIMEVersion = true
+		}
+	}
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil && (strings.HasPrefix(mediaType, "multipart/") || strings.HasPrefix(mediaType, "message/")) {
+			return raw
+		}
+	}
+
+	encoding := bodyEncoding(body, eightBit)
+	switch encoding {
+	case "quoted-printable":
+		body = encodeQuotedPrintable(body)
+	case "base64":
+		body = encodeBase64Lines([]byte(body))
+	}
+	if !hasMIMEVersion {
+		fields = append(fields, "MIME-Version: 1.0")
+	}
+	if contentType == "" {
+		fields = append(fields, "Content-Type: text/plain; charset=utf-8")
+	}
+	if encoding != "7bit" {
+		fields = append(fields, "Content-Transfer-Encoding: "+encoding)
+	}
+	return joinMessage(strings.Join(fields, "\r\n"), body)
+}
+
+// prepareSignedBody labels the body of a CRLF message for an inline
+// signature without re-encoding it, so the signature covers exactly the
+// bytes that are sent. It adds MIME-Version, a text/plain; charset=utf-8
+// Content-Type and a 7bit or 8bit Content-Transfer-Encoding. Bodies that
+// would need quoted-printable or base64 are refused; PGP/MIME signs those.
+func prepareSignedBody(raw string) (string, error) {
+	header, body := splitMessage(raw)
+	fields := headerFields(header)
+	encoding := bodyEncoding(body, true)
+	if encoding != "7bit" && encoding != "8bit" {
+		return "", fmt.Errorf("body needs %s encoding, use PGP/MIME to sign it", encoding)
+	}
+	var contentType, declared string
+	hasMIMEVersion := false
+	for _, f := range fields {
+		switch fieldName(f) {
+		case "content-transfer-encoding":
+			declared = strings.ToLower(strings.TrimSpace(fieldValue(f)))
+		case "content-type":
+			contentType = fieldValue(f)
+		case "mime-version":
+			hasMIMEVersion = true
+		}
+	}
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "text/plain" {
+			return "", fmt.Errorf("inline signatures need a text/plain body, use PGP/MIME")
+		}
+	}
+	switch {
+	case declared == "":
+		fields = append(fields, "Content-Transfer-Encoding: "+encoding)
+	case declared == "7bit" && encoding == "8bit":
+		return "", fmt.Errorf("body is declared 7bit but is not ASCII")
+	case declared != "7bit" && declared != "8bit":
+		return "", fmt.Errorf("body is already %s encoded, use PGP/MIME to sign it", declared)
+	}
+	if !hasMIMEVersion {
+		fields = append(fields, "MIME-Version: 1.0")
+	}
+	if contentType == "" {
+		fields = append(fields, "Content-Type: text/plain; charset=utf-8")
+	}
+	return joinMessage(strings.Join(fields, "\r\n"), body), nil
+}
+
+// needsEightBit reports whether the CRLF message raw declares an 8bit
+// transfer encoding, which only a server with 8BITMIME may relay as is.
+func needsEightBit(raw string) bool {
+	header, _ := splitMessage(raw)
+	for _, f := range headerFields(header) {
+		if fieldName(f) == "content-transfer-encoding" && strings.EqualFold(strings.TrimSpace(fieldValue(f)), "8bit") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+const defaultClipboardClear = 30 * time.Second
+
+// clipboardClearDelay reads the configured auto-clear delay in seconds.
+// An empty value means the default, 0 disables auto-clear.
+func (g *GUI) clipboardClearDelay() time.Duration {
+	value := strings.TrimSpace(g.clipboardClearEntry.Text)
+	if value == "" {
+		return defaultClipboardClear
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return defaultClipboardClear
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// copyToClipboard puts text on the system clipboard and schedules it to
+// be cleared again, unless something else has been copied since.
+func (g *GUI) copyToClipboard(text string) error {
+	if err := clipboard.WriteAll(text); err != nil {
+		return err
+	}
+	gen := g.clipboardGen.Add(1)
+	delay := g.clipboardClearDelay()
+	if delay == 0 {
+		return nil
+	}
+	time.AfterFunc(delay, func() {
+		if g.clipboardGen.Load() != gen {
+			return
+		}
+		if current, err := clipboard.ReadAll(); err == nil && current == text {
+			clipboard.WriteAll("")
+		}
+	})
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	datePolicyExact  = "exact"
+	datePolicyMinute = "minute"
+	datePolicyHour   = "hour"
+	datePolicyJitter = "jitter"
+	datePolicyOmit   = "omit"
+)
+
+var datePolicies = []string{datePolicyExact, datePolicyMinute, datePolicyHour, datePolicyJitter, datePolicyOmit}
+
+// defaultDateJitter is the offset range in minutes used when the jitter
+// entry is empty. maxDateJitter caps it at a day, which keeps the offset
+// in seconds far from overflowing and the Date plausible.
+const (
+	defaultDateJitter = 30
+	maxDateJitter     = 24 * 60
+)
+
+// parseDateJitter reads the jitter range in minutes. An empty entry means
+// the default; anything else must be a whole number of minutes from 1 to
+// maxDateJitter.
+func parseDateJitter(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultDateJitter, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > maxDateJitter {
+		return 0, fmt.Errorf("Date jitter must be a number of minutes from 1 to %d", maxDateJitter)
+	}
+	return n, nil
+}
+
+// policyDate returns the value for an automatic Date header, or "" when
+// policy omits it. Rounding truncates so the Date never lies in the
+// future; jitter moves it by a random number of seconds within ±minutes.
+// A jitter the random source cannot supply is an error rather than a
+// silent fallback to the exact time.
+func policyDate(now time.Time, policy string, minutes int) (string, error) {
+	now = now.UTC()
+	switch policy {
+	case datePolicyOmit:
+		return "", nil
+	case datePolicyMinute:
+		now = now.Truncate(time.Minute)
+	case datePolicyHour:
+		now = now.Truncate(time.Hour)
+	case datePolicyJitter:
+		if minutes <= 0 || minutes > maxDateJitter {
+			return "", fmt.Errorf("date jitter of %d minutes is out of range", minutes)
+		}
+		span := int64(minutes) * 60
+		n, err := rand.Int(rand.Reader, big.NewInt(2*span+1))
+		if err != nil {
+			return "", fmt.Errorf("date jitter: %v", err)
+		}
+		now = now.Add(time.Duration(n.Int64()-span) * time.Second)
+	}
+	return now.Format(time.RFC1123Z), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseDateJitter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"", defaultDateJitter, true},
+		{"  ", defaultDateJitter, true},
+		{"1", 1, true},
+		{" 45 ", 45, true},
+		{"1440", maxDateJitter, true},
+		{"1441", 0, false},
+		{"0", 0, false},
+		{"-5", 0, false},
+		{"ten", 0, false},
+		{"1.5", 0, false},
+		{strconv.Itoa(math.MaxInt64 / 60), 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseDateJitter(tt.in)
+			if (err == nil) != tt.ok {
+				t.Fatalf("parseDateJitter(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			}
+			if got != tt.want {
+				t.Errorf("parseDateJitter(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyDate(t *testing.T) {
+	now := time.Date(2024, 3, 9, 14, 37, 52, 123456789, time.FixedZone("CET", 3600))
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{datePolicyExact, "Sat, 09 Mar 2024 13:37:52 +0000"},
+		{datePolicyMinute, "Sat, 09 Mar 2024 13:37:00 +0000"},
+		{datePolicyHour, "Sat, 09 Mar 2024 13:00:00 +0000"},
+		{datePolicyOmit, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			got, err := policyDate(now, tt.policy, defaultDateJitter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("policyDate(%s) = %q, want %q", tt.policy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyDateJitter(t *testing.T) {
+	now := time.Date(2024, 3, 9, 13, 37, 52, 0, time.UTC)
+	for _, minutes := range []int{1, defaultDateJitter, maxDateJitter} {
+		span := time.Duration(minutes) * time.Minute
+		for i := 0; i < 50; i++ {
+			got, err := policyDate(now, datePolicyJitter, minutes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			date, err := time.Parse(time.RFC1123Z, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := date.Sub(now); d < -span || d > span {
+				t.Fatalf("jitter of %d minutes moved the date by %v", minutes, d)
+			}
+		}
+	}
+	for _, minutes := range []int{0, -1, maxDateJitter + 1, math.MaxInt64 / 60, math.MaxInt64} {
+		if got, err := policyDate(now, datePolicyJitter, minutes); err == nil {
+			t.Errorf("policyDate accepted a jitter of %d minutes: %q", minutes, got)
+		}
+	}
+}
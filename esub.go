@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/blowfish"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/sha3"
+)
+
+// esub schemes carry a version tag so the stored choice stays meaningful
+// if either format ever changes.
+const (
+	esubSchemeMMG     = "mmg-v1"
+	esubSchemeClassic = "classic-v1"
+)
+
+// esubSchemes lists the selectable schemes, default first.
+var esubSchemes = []string{esubSchemeMMG, esubSchemeClassic}
+
+// esubDefaultText is the plaintext hashed by the mmg scheme; it is also
+// used for the classic scheme when no subject text is given.
+const esubDefaultText = "text"
+
+type esub struct {
+	key     []byte
+	subject string
+	text    string
+	scheme  string
+}
+
+func (e *esub) plaintext() string {
+	if e.text == "" {
+		return esubDefaultText
+	}
+	return e.text
+}
+
+// esubKeyCache holds Argon2id-derived esub keys for the lifetime of the
+// session, indexed by a hash of the passphrase so the passphrase itself is
+// not retained. Call wipe before exiting.
+type esubKeyCache struct {
+	mu   sync.Mutex
+	keys map[[32]byte][]byte
+}
+
+var esubKeys = &esubKeyCache{keys: make(map[[32]byte][]byte)}
+
+func (c *esubKeyCache) get(passphrase []byte) []byte {
+	id := sha3.Sum256(passphrase)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.keys[id]; ok {
+		return key
+	}
+	salt := []byte("fixed-salt-1234")
+	key := argon2.IDKey(
+		passphrase,
+		salt,
+		3,
+		64*1024,
+		4,
+		32,
+	)
+	lockMemory(key)
+	c.keys[id] = key
+	return key
+}
+
+// wipe zeroes every cached key and empties the cache.
+func (c *esubKeyCache) wipe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, key := range c.keys {
+		wipeBytes(key)
+		delete(c.keys, id)
+	}
+}
+
+func (e *esub) deriveKey() []byte {
+	return esubKeys.get(e.key)
+}
+
+func (e *esub) esubtest() bool {
+	if len(e.subject) != 48 {
+		return false
+	}
+
+	esubBytes, err := hex.DecodeString(e.subject)
+	if err != nil || len(esubBytes) != 24 {
+		return false
+	}
+
+	var expected []byte
+	switch e.scheme {
+	case esubSchemeClassic:
+		expected, err = e.classic(esubBytes[:8])
+	case esubSchemeMMG, "":
+		expected, err = e.mmg(esubBytes[:12])
+	default:
+		return false
+	}
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(expected, esubBytes) == 1
+}
+
+// esubtestBatch tests every subject against e's key, text and scheme in
+// parallel and reports the matches in input order.
+func (e *esub) esubtestBatch(subjects []string) []bool {
+	results := make([]bool, len(subjects))
+	if len(subjects) == 0 {
+		return results
+	}
+	if e.scheme == esubSchemeMMG || e.scheme == "" {
+		// Derive once up front instead of racing every worker into Argon2.
+		e.deriveKey()
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				c := *e
+				c.subject = subjects[i]
+				results[i] = c.esubtest()
+			}
+		}()
+	}
+	for i := range subjects {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (e *esub) esubgen() (string, error) {
+	var (
+		out []byte
+		err error
+	)
+	switch e.scheme {
+	case esubSchemeClassic:
+		iv := make([]byte, 8)
+		if _, err := rand.Read(iv); err != nil {
+			return "", err
+		}
+		out, err = e.classic(iv)
+	case esubSchemeMMG, "":
+		nonce := make([]byte, 12)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		out, err = e.mmg(nonce)
+	default:
+		return "", fmt.Errorf("unknown esub scheme %q", e.scheme)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(out), nil
+}
+
+// mmg builds the original mmg esub: a 12 byte nonce followed by the first
+// 12 bytes of SHA3-256(text) encrypted with ChaCha20 under an Argon2id key.
+func (e *esub) mmg(nonce []byte) ([]byte, error) {
+	c, err := chacha20.NewUnauthenticatedCipher(e.deriveKey(), nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	textHash := sha3.Sum256([]byte(e.plaintext()))
+	ciphertext := make([]byte, 12)
+	c.XORKeyStream(ciphertext, textHash[:12])
+
+	return append(append([]byte{}, nonce...), ciphertext...), nil
+}
+
+// classic builds the 192 bit esub understood by nym servers and
+// alt.anonymous.messages readers: an 8 byte IV followed by MD5(text)
+// encrypted in two Blowfish-OFB steps under MD5(key), each truncated
+// to 8 bytes, the second one chained from the first.
+func (e *esub) classic(iv []byte) ([]byte, error) {
+	keyHash := md5.Sum(e.key)
+	textHash := md5.Sum([]byte(e.plaintext()))
+
+	block, err := blowfish.NewCipher(keyHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	crypt1 := make([]byte, 16)
+	cipher.NewOFB(block, iv).XORKeyStream(crypt1, textHash[:])
+	crypt1 = crypt1[:8]
+
+	crypt2 := make([]byte, 8)
+	cipher.NewOFB(block, crypt1).XORKeyStream(crypt2, textHash[8:])
+
+	out := append(append([]byte{}, iv...), crypt1...)
+	return append(out, crypt2...), nil
+}
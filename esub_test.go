@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// The classic vectors follow the reference esub algorithm step by step
+// with an independent Blowfish: MD5 of key and text, then two OFB
+// encryptions with openssl, e.g.
+//
+//	openssl enc -provider legacy -provider default -bf-ofb -nopad \
+//		-K $(printf 'mmg test key' | openssl md5 -binary | xxd -p) -iv 0123456789abcdef
+//
+// over the first half of MD5(text), and again over the second half with
+// the first result as IV.
+var classicVectors = []struct {
+	key, text, iv, want string
+}{
+	{"mmg test key", "text", "0123456789abcdef", "0123456789abcdefdf675d7f0260d855842477eaf88e9853"},
+	{"correct horse", "alt.anonymous.messages", "f0e1d2c3b4a59687", "f0e1d2c3b4a59687807503179b4fcb6bfd420a837ba4bb2a"},
+}
+
+func TestClassicKnownAnswer(t *testing.T) {
+	for _, v := range classicVectors {
+		iv, _ := hex.DecodeString(v.iv)
+		e := esub{key: []byte(v.key), text: v.text, scheme: esubSchemeClassic}
+		out, err := e.classic(iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(out); got != v.want {
+			t.Errorf("classic(%q, %q) = %s, want %s", v.key, v.text, got, v.want)
+		}
+
+		e.subject = v.want
+		if !e.esubtest() {
+			t.Errorf("esubtest rejected the reference esub %s", v.want)
+		}
+	}
+}
+
+func TestEsubRoundTrip(t *testing.T) {
+	for _, scheme := range esubSchemes {
+		t.Run(scheme, func(t *testing.T) {
+			e := esub{key: []byte("round trip key"), text: "some subject", scheme: scheme}
+			subject, err := e.esubgen()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(subject) != 48 {
+				t.Fatalf("esub %q is not 48 hex characters", subject)
+			}
+
+			e.subject = subject
+			if !e.esubtest() {
+				t.Error("esubtest rejected its own esub")
+			}
+
+			wrongKey := esub{key: []byte("other key"), text: e.text, subject: subject, scheme: scheme}
+			if wrongKey.esubtest() {
+				t.Error("esubtest accepted the esub under another key")
+			}
+			wrongText := esub{key: e.key, text: "other subject", subject: subject, scheme: scheme}
+			if wrongText.esubtest() {
+				t.Error("esubtest accepted the esub for another text")
+			}
+			for _, other := range esubSchemes {
+				if other == scheme {
+					continue
+				}
+				wrongScheme := esub{key: e.key, text: e.text, subject: subject, scheme: other}
+				if wrongScheme.esubtest() {
+					t.Errorf("esubtest accepted the esub under scheme %s", other)
+				}
+			}
+
+			batch := e.esubtestBatch([]string{"unrelated", subject, ""})
+			if batch[0] || !batch[1] || batch[2] {
+				t.Errorf("esubtestBatch = %v, want [false true false]", batch)
+			}
+		})
+	}
+}
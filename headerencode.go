@@ -0,0 +1,133 @@
+package main
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+)
+
+// maxHeaderLine is the line length RFC 2047 encoded headers are folded
+// at.
+const maxHeaderLine = 76
+
+// addressFields are the headers whose values are address lists; only
+// their display names may be encoded.
+var addressFields = []string{
+	"from", "sender", "reply-to", "to", "cc", "bcc",
+	"resent-from", "resent-sender", "resent-to", "resent-cc", "resent-bcc",
+	"mail-followup-to", "mail-reply-to",
+}
+
+// encodeHeaders applies RFC 2047 encoding to every header of a CRLF
+// message that contains non-ASCII text. Address headers keep their
+// addr-specs as written and have only the display names encoded; other
+// headers are treated as unstructured text. Encoded fields are folded
+// at 76 characters. ASCII-only fields are left exactly as they are.
+func encodeHeaders(raw string) string {
+	header, body := splitMessage(raw)
+	fields := headerFields(header)
+	changed := false
+	for i, f := range fields {
+		colon := strings.Index(f, ":")
+		if isASCII(f) || colon < 0 {
+			continue
+		}
+		name := f[:colon]
+		value := strings.TrimSpace(strings.ReplaceAll(f[colon+1:], "\r\n", ""))
+		var words []string
+		if validChoice(addressFields, fieldName(f)) {
+			words = encodeAddressList(value)
+		} else {
+			words = encodeUnstructured(value)
+		}
+		fields[i] = foldHeader(name+":", words)
+		changed = true
+	}
+	if !changed {
+		return raw
+	}
+	return joinMessage(strings.Join(fields, "\r\n"), body)
+}
+
+// encodeWords encodes s as one or more encoded-words of at most 75
+// characters each. Q encoding is used for mostly-ASCII text, B otherwise;
+// phrase selects B always, since Q may leave characters that are special
+// in a display name.
+func encodeWords(s string, phrase bool) []string {
+	nonASCII := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			nonASCII++
+		}
+	}
+	enc := mime.BEncoding
+	if !phrase && nonASCII*3 < len(s) {
+		enc = mime.QEncoding
+	}
+	return strings.Split(enc.Encode("UTF-8", s), " ")
+}
+
+// encodeUnstructured encodes the runs of words in value that need it and
+// leaves plain ASCII words readable.
+func encodeUnstructured(value string) []string {
+	var words, run []string
+	flush := func() {
+		if len(run) > 0 {
+			words = append(words, encodeWords(strings.Join(run, " "), false)...)
+			run = nil
+		}
+	}
+	for _, w := range strings.Fields(value) {
+		if !isASCII(w) {
+			run = append(run, w)
+			continue
+		}
+		flush()
+		words = append(words, w)
+	}
+	flush()
+	return words
+}
+
+// encodeAddressList encodes the display names of an address list. If the
+// list does not parse it is treated as unstructured text.
+func encodeAddressList(value string) []string {
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return encodeUnstructured(value)
+	}
+	var words []string
+	for i, a := range list {
+		spec := (&mail.Address{Address: a.Address}).String()
+		switch {
+		case a.Name == "":
+			words = append(words, strings.Trim(spec, "<>"))
+		case isASCII(a.Name):
+			words = append(words, strings.Fields(a.String())...)
+		default:
+			words = append(words, encodeWords(a.Name, true)...)
+			words = append(words, spec)
+		}
+		if i < len(list)-1 {
+			words[len(words)-1] += ","
+		}
+	}
+	return words
+}
+
+// foldHeader joins words after prefix with single spaces, starting a
+// continuation line whenever a line would exceed maxHeaderLine.
+func foldHeader(prefix string, words []string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	lineLen := len(prefix)
+	for _, w := range words {
+		if lineLen+1+len(w) > maxHeaderLine && lineLen > 1 {
+			b.WriteString("\r\n")
+			lineLen = 0
+		}
+		b.WriteString(" " + w)
+		lineLen += 1 + len(w)
+	}
+	return b.String()
+}
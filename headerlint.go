@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+const (
+	headerLintOff   = "off"
+	headerLintWarn  = "warn"
+	headerLintBlock = "block"
+)
+
+var headerLintPolicies = []string{headerLintOff, headerLintWarn, headerLintBlock}
+
+// clientHeaders name the mail or news client that wrote a message.
+var clientHeaders = []string{
+	"user-agent", "x-mailer", "x-newsreader", "x-mimeole", "thread-index",
+	"x-ms-has-attach", "x-ms-tnef-correlator", "x-ms-exchange-organization-authas",
+}
+
+// networkHeaders reveal a relay path or the sender's address.
+var networkHeaders = []string{
+	"received", "x-originating-ip", "x-sender-ip", "x-forwarded-for",
+	"x-real-ip", "x-authenticated-sender", "x-originating-host",
+}
+
+// addressDomain returns the lower-cased domain of the first address in
+// value, or "" if it has none.
+func addressDomain(value string) string {
+	addr := firstAddress(value)
+	if at := strings.LastIndex(addr, "@"); at >= 0 {
+		return strings.ToLower(strings.Trim(addr[at+1:], "<> "))
+	}
+	return ""
+}
+
+// lintHeaders lists the headers of a CRLF message that identify the
+// sender or the software they use. It is used before sending, since
+// templates pasted from regular mail clients often carry these along.
+func lintHeaders(raw string) []string {
+	header, _ := splitMessage(raw)
+	var findings []string
+	var from, messageID string
+	for _, f := range headerFields(header) {
+		colon := strings.Index(f, ":")
+		if colon < 0 {
+			continue
+		}
+		name := strings.TrimSpace(f[:colon])
+		value := strings.TrimSpace(strings.ReplaceAll(f[colon+1:], "\r\n", ""))
+		switch key := fieldName(f); {
+		case validChoice(clientHeaders, key):
+			findings = append(findings, fmt.Sprintf("%s identifies the client: %s", name, value))
+		case validChoice(networkHeaders, key):
+			findings = append(findings, fmt.Sprintf("%s reveals a network path or address", name))
+		case key == "organization":
+			findings = append(findings, fmt.Sprintf("Organization names the sender's organization: %s", value))
+		case key == "date":
+			if t, err := mail.ParseDate(value); err == nil {
+				if _, offset := t.Zone(); offset != 0 {
+					findings = append(findings, fmt.Sprintf("Date shows a local time zone (%s)", t.Format("-0700")))
+				}
+			}
+		case key == "from":
+			from = value
+		case key == "message-id":
+			messageID = value
+		}
+	}
+	if fromDomain, idDomain := addressDomain(from), addressDomain(messageID); fromDomain != "" &&
+		(idDomain == fromDomain || strings.HasSuffix(idDomain, "."+fromDomain)) {
+		findings = append(findings, fmt.Sprintf("Message-ID domain %s matches the From domain", idDomain))
+	}
+	return findings
+}
+
+// checkHeaders runs lintHeaders over raw and applies the profile's
+// policy: findings are ignored, shown with a choice to send anyway, or
+// stop the message. next is called when sending may go ahead.
+func (g *GUI) checkHeaders(raw string, next func()) {
+	policy := g.headerLintSelect.Selected
+	if policy == headerLintOff {
+		next()
+		return
+	}
+	findings := lintHeaders(raw)
+	if len(findings) == 0 {
+		next()
+		return
+	}
+	text := strings.Join(findings, "\n")
+	if policy == headerLintBlock {
+		dialog.ShowError(fmt.Errorf("Identifying headers found:\n%s", text), g.window)
+		return
+	}
+	dialog.ShowConfirm("Header Warnings", "Identifying headers found:\n"+text+"\n\nSend anyway?", func(ok bool) {
+		if ok {
+			next()
+		}
+	}, g.window)
+}
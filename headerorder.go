@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	headerOrderKeep        = "as written"
+	headerOrderThunderbird = "thunderbird"
+	headerOrderRandom      = "random"
+)
+
+var headerOrders = []string{headerOrderKeep, headerOrderThunderbird, headerOrderRandom}
+
+// thunderbirdOrder is the order Thunderbird writes its headers in.
+// Headers not listed keep their relative order between Subject and the
+// MIME content headers.
+var thunderbirdOrder = []string{
+	"message-id", "date", "mime-version", "user-agent", "content-language",
+	"newsgroups", "followup-to", "to", "cc", "bcc", "from", "reply-to",
+	"subject", "references", "in-reply-to",
+}
+
+// contentHeaders close the header block in thunderbirdOrder.
+var contentHeaders = []string{"content-type", "content-disposition", "content-transfer-encoding"}
+
+// traceHeaders are prepended by relays and must stay at the top.
+var traceHeaders = []string{"return-path", "received"}
+
+// orderHeaders reorders the header fields of a CRLF message so that the
+// order no longer shows which template or program wrote it. Trace
+// fields stay first in every mode.
+func orderHeaders(raw, order string) (string, error) {
+	if order != headerOrderThunderbird && order != headerOrderRandom {
+		return raw, nil
+	}
+	header, body := splitMessage(raw)
+	var trace, rest []string
+	for _, f := range headerFields(header) {
+		if validChoice(traceHeaders, fieldName(f)) {
+			trace = append(trace, f)
+		} else {
+			rest = append(rest, f)
+		}
+	}
+
+	var ordered []string
+	if order == headerOrderThunderbird {
+		ordered = fixedOrder(rest)
+	} else {
+		// Fisher-Yates with crypto/rand, so the order cannot be predicted.
+		ordered = append(ordered, rest...)
+		for i := len(ordered) - 1; i > 0; i-- {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return "", err
+			}
+			j := int(n.Int64())
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+	return joinMessage(strings.Join(append(trace, ordered...), "\r\n"), body), nil
+}
+
+func fixedOrder(fields []string) []string {
+	var ordered []string
+	taken := make([]bool, len(fields))
+	pick := func(name string) {
+		for i, f := range fields {
+			if !taken[i] && fieldName(f) == name {
+				ordered = append(ordered, f)
+				taken[i] = true
+			}
+		}
+	}
+	for _, name := range thunderbirdOrder {
+		pick(name)
+	}
+	for i, f := range fields {
+		if !taken[i] && !validChoice(contentHeaders, fieldName(f)) {
+			ordered = append(ordered, f)
+			taken[i] = true
+		}
+	}
+	for _, name := range contentHeaders {
+		pick(name)
+	}
+	return ordered
+}
+
+// finalizeHeaders is the last step before a message or article goes to a
+// server, shared by the SMTP and NNTP routes: it applies the profile's
+// header order and checks the result with checkHeaderBlock.
+func (g *GUI) finalizeHeaders(raw string) (string, error) {
+	raw, err := orderHeaders(raw, g.headerOrderSelect.Selected)
+	if err != nil {
+		return "", fmt.Errorf("failed to order headers: %v", err)
+	}
+	if err := checkHeaderBlock(raw); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+)
+
+// splitMessage separates the header block of a CRLF message from its
+// body. The header block is returned without the terminating blank line.
+func splitMessage(raw string) (header, body string) {
+	parts := strings.SplitN(raw, "\r\n\r\n", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return strings.TrimSuffix(raw, "\r\n"), ""
+}
+
+// joinMessage is the inverse of splitMessage.
+func joinMessage(header, body string) string {
+	return header + "\r\n\r\n" + body
+}
+
+// headerFields splits a header block into fields, keeping folded
+// continuation lines together with the field they belong to.
+func headerFields(header string) []string {
+	var fields []string
+	for _, line := range strings.Split(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// fieldName returns the lower-cased name of a header field.
+func fieldName(field string) string {
+	if i := strings.Index(field, ":"); i >= 0 {
+		return strings.ToLower(strings.TrimSpace(field[:i]))
+	}
+	return ""
+}
+
+// fieldValue returns the unfolded value of a header field.
+func fieldValue(field string) string {
+	i := strings.Index(field, ":")
+	if i < 0 {
+		return ""
+	}
+	value := strings.ReplaceAll(field[i+1:], "\r\n", "")
+	return strings.TrimSpace(value)
+}
+
+// removeFields drops every field whose name is in names (lower case).
+func removeFields(fields []string, names ...string) []string {
+	var kept []string
+	for _, f := range fields {
+		drop := false
+		for _, n := range names {
+			if fieldName(f) == n {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
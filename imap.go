@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	imapSecurityStartTLS = "starttls"
+	imapSecurityTLS      = "imaps"
+	imapSecurityNone     = "none"
+)
+
+var imapSecurities = []string{imapSecurityStartTLS, imapSecurityTLS, imapSecurityNone}
+
+// imapWindow is how many of the newest messages a folder listing shows.
+const imapWindow = 200
+
+// imapClient is a minimal read-only IMAP4rev1 client. Folders are opened
+// with EXAMINE and bodies fetched with BODY.PEEK, so nothing on the
+// server changes, not even \Seen flags.
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	host string
+	tls  bool
+	seq  int
+}
+
+// imapResponse is one server response line. Status responses (OK, NO,
+// BAD, BYE, PREAUTH) and continuations keep their text in text; data
+// responses are parsed into fields, where a value is a string, nil for
+// NIL, or a []interface{} for a parenthesized list.
+type imapResponse struct {
+	tag    string
+	status string
+	text   string
+	fields []interface{}
+}
+
+func imapDefaultPort(security string) string {
+	if security == imapSecurityTLS {
+		return "993"
+	}
+	return "143"
+}
+
+// dialIMAP connects to host:port through dialer and reads the greeting,
+// upgrading the connection to TLS as security asks.
+func dialIMAP(dialer proxy.Dialer, host, port, security string) (*imapClient, error) {
+	if port == "" {
+		port = imapDefaultPort(security)
+	}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	c := &imapClient{conn: conn, host: host}
+	if security == imapSecurityTLS {
+		c.startTLS()
+	}
+	c.r = bufio.NewReader(c.conn)
+
+	greeting, err := c.readResponse()
+	if err == nil && greeting.status != "OK" && greeting.status != "PREAUTH" {
+		err = fmt.Errorf("%s %s", greeting.status, greeting.text)
+	}
+	if err != nil {
+		c.conn.Close()
+		return nil, fmt.Errorf("greeting: %v", err)
+	}
+	if security == imapSecurityStartTLS {
+		if _, err := c.cmd("STARTTLS"); err != nil {
+			c.conn.Close()
+			return nil, fmt.Errorf("STARTTLS: %v", err)
+		}
+		c.startTLS()
+		c.r = bufio.NewReader(c.conn)
+	}
+	return c, nil
+}
+
+func (c *imapClient) startTLS() {
+	c.conn = tls.Client(c.conn, &tls.Config{ServerName: c.host, InsecureSkipVerify: true})
+	c.tls = true
+}
+
+func (c *imapClient) nextTag() string {
+	c.seq++
+	return fmt.Sprintf("a%03d", c.seq)
+}
+
+// cmd sends a tagged command and collects the untagged responses up to
+// its completion, which must be OK.
+func (c *imapClient) cmd(format string, args ...interface{}) ([]imapResponse, error) {
+	line, err := commandLine(format, args...)
+	if err != nil {
+		return nil, err
+	}
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, line); err != nil {
+		return nil, err
+	}
+	return c.complete(tag)
+}
+
+func (c *imapClient) complete(tag string) ([]imapResponse, error) {
+	var untagged []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return untagged, err
+		}
+		if resp.tag != tag {
+			untagged = append(untagged, resp)
+			continue
+		}
+		if resp.status != "OK" {
+			return untagged, fmt.Errorf("%s %s", resp.status, resp.text)
+		}
+		return untagged, nil
+	}
+}
+
+// imapQuote returns s as an IMAP quoted string.
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// login runs LOGIN, refusing to do so over a cleartext connection except
+// to localhost or an onion service.
+func (c *imapClient) login(username string, password []byte) error {
+	if !c.tls && !isLocalhost(c.host) && !strings.HasSuffix(c.host, ".onion") {
+		return errors.New("unencrypted connection")
+	}
+	if err := checkLine("username", username); err != nil {
+		return err
+	}
+	if err := checkSecret("password", password); err != nil {
+		return err
+	}
+	tag := c.nextTag()
+	line := make([]byte, 0, len(tag)+len(username)+2*len(password)+16)
+	line = append(line, tag+" LOGIN "+imapQuote(username)+` "`...)
+	for _, b := range password {
+		if b == '"' || b == '\\' {
+			line = append(line, '\\')
+		}
+		line = append(line, b)
+	}
+	line = append(line, "\"\r\n"...)
+	lockMemory(line)
+	_, err := c.conn.Write(line)
+	wipeBytes(line)
+	if err != nil {
+		return err
+	}
+	_, err = c.complete(tag)
+	return err
+}
+
+func (c *imapClient) logout() {
+	c.cmd("LOGOUT")
+	c.conn.Close()
+}
+
+// folders lists every mailbox name.
+func (c *imapClient) folders() ([]string, error) {
+	resps, err := c.cmd(`LIST "" "*"`)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range resps {
+		if len(r.fields) < 4 || !strings.EqualFold(imapString(r.fields[0]), "LIST") {
+			continue
+		}
+		if flags, ok := r.fields[1].([]interface{}); ok && imapHasFlag(flags, `\Noselect`) {
+			continue
+		}
+		names = append(names, imapString(r.fields[3]))
+	}
+	return names, nil
+}
+
+func imapHasFlag(flags []interface{}, flag string) bool {
+	for _, f := range flags {
+		if strings.EqualFold(imapString(f), flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// examine opens folder read-only and returns its message count.
+func (c *imapClient) examine(folder string) (int, error) {
+	resps, err := c.cmd("EXAMINE %s", imapQuote(folder))
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range resps {
+		if len(r.fields) == 2 && strings.EqualFold(imapString(r.fields[1]), "EXISTS") {
+			return strconv.Atoi(imapString(r.fields[0]))
+		}
+	}
+	return 0, nil
+}
+
+// imapEnvelope is the part of an ENVELOPE the inbox shows.
+type imapEnvelope struct {
+	UID       uint32
+	Date      string
+	Subject   string
+	From      string
+	MessageID string
+}
+
+// envelopes fetches the envelopes of the newest imapWindow messages of a
+// folder holding exists messages, newest first.
+func (c *imapClient) envelopes(exists int) ([]imapEnvelope, error) {
+	if exists == 0 {
+		return nil, nil
+	}
+	start := exists - imapWindow + 1
+	if start < 1 {
+		start = 1
+	}
+	resps, err := c.cmd("FETCH %d:%d (UID ENVELOPE)", start, exists)
+	if err != nil {
+		return nil, err
+	}
+	var envs []imapEnvelope
+	for _, r := range resps {
+		attrs := imapFetchAttrs(r)
+		if attrs == nil {
+			continue
+		}
+		uid, _ := strconv.ParseUint(imapString(attrs["UID"]), 10, 32)
+		env, _ := attrs["ENVELOPE"].([]interface{})
+		if uid == 0 || len(env) < 10 {
+			continue
+		}
+		envs = append(envs, imapEnvelope{
+			UID:       uint32(uid),
+			Date:      imapString(env[0]),
+			Subject:   decodeHeader(imapString(env[1])),
+			From:      imapAddresses(env[2]),
+			MessageID: imapString(env[9]),
+		})
+	}
+	for i, j := 0, len(envs)-1; i < j; i, j = i+1, j-1 {
+		envs[i], envs[j] = envs[j], envs[i]
+	}
+	return envs, nil
+}
+
+// body fetches the full message with the given UID without setting
+// \Seen.
+func (c *imapClient) body(uid uint32) (string, error) {
+	resps, err := c.cmd("UID FETCH %d (BODY.PEEK[])", uid)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range resps {
+		if attrs := imapFetchAttrs(r); attrs != nil {
+			if v, ok := attrs["BODY[]"]; ok {
+				return imapString(v), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("message %d not found", uid)
+}
+
+// imapFetchAttrs returns the attributes of a "* n FETCH (...)" response
+// by name, or nil for any other response.
+func imapFetchAttrs(r imapResponse) map[string]interface{} {
+	if len(r.fields) != 3 || !strings.EqualFold(imapString(r.fields[1]), "FETCH") {
+		return nil
+	}
+	list, ok := r.fields[2].([]interface{})
+	if !ok {
+		return nil
+	}
+	attrs := make(map[string]interface{})
+	for i := 0; i+1 < len(list); i += 2 {
+		attrs[strings.ToUpper(imapString(list[i]))] = list[i+1]
+	}
+	return attrs
+}
+
+func imapString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// imapAddresses formats an ENVELOPE address list.
+func imapAddresses(v interface{}) string {
+	list, _ := v.([]interface{})
+	var out []string
+	for _, a := range list {
+		addr, _ := a.([]interface{})
+		if len(addr) < 4 {
+			continue
+		}
+		email := imapString(addr[2]) + "@" + imapString(addr[3])
+		if name := decodeHeader(imapString(addr[0])); name != "" {
+			out = append(out, name+" <"+email+">")
+		} else {
+			out = append(out, email)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// decodeHeader decodes RFC 2047 encoded-words for display, returning the
+// input unchanged if it cannot be decoded.
+func decodeHeader(s string) string {
+	dec := new(mime.WordDecoder)
+	if out, err := dec.DecodeHeader(s); err == nil {
+		return out
+	}
+	return s
+}
+
+// readResponse reads one response line, reading any literals it
+// contains.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	tag, err := c.readAtom()
+	if err != nil {
+		return resp, err
+	}
+	resp.tag = tag
+	if tag == "+" {
+		resp.text, err = c.readText()
+		return resp, err
+	}
+	if err := c.skipSpace(); err != nil {
+		return resp, err
+	}
+	first, err := c.readAtom()
+	if err != nil {
+		return resp, err
+	}
+	switch strings.ToUpper(first) {
+	case "OK", "NO", "BAD", "BYE", "PREAUTH":
+		resp.status = strings.ToUpper(first)
+		resp.text, err = c.readText()
+		return resp, err
+	}
+	resp.fields = []interface{}{first}
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return resp, err
+		}
+		switch b {
+		case ' ':
+			continue
+		case '\r':
+			if b, err = c.r.ReadByte(); err != nil {
+				return resp, err
+			}
+			if b != '\n' {
+				return resp, errors.New("malformed line ending")
+			}
+			return resp, nil
+		}
+		c.r.UnreadByte()
+		v, err := c.readValue()
+		if err != nil {
+			return resp, err
+		}
+		resp.fields = append(resp.fields, v)
+	}
+}
+
+func (c *imapClient) skipSpace() error {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != ' ' {
+		c.r.UnreadByte()
+	}
+	return nil
+}
+
+// readText returns the rest of the line.
+func (c *imapClient) readText() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (c *imapClient) readValue() (interface{}, error) {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case '(':
+		var list []interface{}
+		for {
+			b, err := c.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			switch b {
+			case ')':
+				return list, nil
+			case ' ':
+				continue
+			case '\r', '\n':
+				return nil, errors.New("unterminated list")
+			}
+			c.r.UnreadByte()
+			v, err := c.readValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case '"':
+		var sb strings.Builder
+		for {
+			b, err := c.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			switch b {
+			case '"':
+				return sb.String(), nil
+			case '\\':
+				if b, err = c.r.ReadByte(); err != nil {
+					return nil, err
+				}
+			case '\r', '\n':
+				return nil, errors.New("unterminated string")
+			}
+			sb.WriteByte(b)
+		}
+	case '{':
+		size, err := c.r.ReadString('}')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(size, "}"), "+"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("malformed literal size %q", size)
+		}
+		if crlf, err := c.r.ReadString('\n'); err != nil || crlf != "\r\n" {
+			return nil, errors.New("malformed literal")
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	c.r.UnreadByte()
+	atom, err := c.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(atom, "NIL") {
+		return nil, nil
+	}
+	return atom, nil
+}
+
+// readAtom reads an atom. A bracketed section such as BODY[HEADER] is
+// taken whole, spaces and parentheses included.
+func (c *imapClient) readAtom() (string, error) {
+	var sb strings.Builder
+	depth := 0
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case b == '[':
+			depth++
+		case b == ']' && depth > 0:
+			depth--
+		case depth == 0 && (b == ' ' || b == '(' || b == ')' || b == '\r' || b == '\n'):
+			c.r.UnreadByte()
+			if sb.Len() == 0 {
+				return "", fmt.Errorf("unexpected %q", b)
+			}
+			return sb.String(), nil
+		case b == '\r' || b == '\n':
+			return "", errors.New("unterminated section")
+		}
+		sb.WriteByte(b)
+	}
+}
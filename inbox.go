@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"golang.org/x/net/proxy"
+)
+
+// withIMAP opens an IMAP session for the current profile through the
+// SOCKS5 proxy, calls run and logs out again. run is called off the UI
+// thread; done is called on it once run succeeded.
+func (g *GUI) withIMAP(run func(c *imapClient) error, done func()) {
+	host := strings.TrimSpace(g.imapHostEnt.Text)
+	if host == "" {
+		dialog.ShowError(fmt.Errorf("No IMAP server configured"), g.window)
+		return
+	}
+	port := strings.TrimSpace(g.imapPortEnt.Text)
+	security := g.imapSecuritySelect.Selected
+	username := g.imapUsernameEnt.Text
+	password := secretBytes(g.imapPasswordEnt.Text)
+
+	go func() {
+		defer wipeBytes(password)
+
+		updateStatus := func(text string) {
+			fyne.Do(func() {
+				g.statusLabel.SetText(text)
+			})
+		}
+
+		showError := func(err error) {
+			fyne.Do(func() {
+				dialog.ShowError(err, g.window)
+			})
+		}
+
+		updateStatus("Connecting to SOCKS proxy...")
+		dialer, err := proxy.SOCKS5("tcp", "127.0.0.1:"+g.socksPortEnt.Text, nil, proxy.Direct)
+		if err != nil {
+			updateStatus("SOCKS Error: " + err.Error())
+			showError(fmt.Errorf("SOCKS5 error: %v", err))
+			return
+		}
+
+		updateStatus("Connecting to IMAP server...")
+		client, err := dialIMAP(dialer, host, port, security)
+		if err != nil {
+			updateStatus("Connection Error: " + err.Error())
+			showError(fmt.Errorf("Connection failed: %v", err))
+			return
+		}
+		defer client.logout()
+
+		updateStatus("Authenticating...")
+		if err := client.login(username, password); err != nil {
+			updateStatus("Auth Error: " + err.Error())
+			showError(fmt.Errorf("Auth failed: %v", err))
+			return
+		}
+
+		if err := run(client); err != nil {
+			updateStatus("IMAP Error: " + err.Error())
+			showError(fmt.Errorf("IMAP failed: %v", err))
+			return
+		}
+		updateStatus("Ready")
+		fyne.Do(done)
+	}()
+}
+
+// buildInboxTab shows the profile's IMAP mailbox read-only: folders,
+// the newest envelopes of the selected folder and the selected message
+// as plain text.
+func (g *GUI) buildInboxTab() *fyne.Container {
+	var envelopes []imapEnvelope
+	var current string
+	viewer := widget.NewMultiLineEntry()
+	viewer.TextStyle = fyne.TextStyle{Monospace: true}
+	viewer.Wrapping = fyne.TextWrapWord
+
+	folderSelect := widget.NewSelect(nil, nil)
+	folderSelect.PlaceHolder = "Connect to list folders"
+
+	list := widget.NewList(
+		func() int { return len(envelopes) },
+		func() fyne.CanvasObject { return widget.NewLabel("envelope") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			e := envelopes[id]
+			o.(*widget.Label).SetText(e.From + " - " + e.Subject)
+		},
+	)
+
+	loadFolder := func(folder string) {
+		var envs []imapEnvelope
+		g.withIMAP(func(c *imapClient) error {
+			exists, err := c.examine(folder)
+			if err != nil {
+				return err
+			}
+			envs, err = c.envelopes(exists)
+			return err
+		}, func() {
+			envelopes = envs
+			current = ""
+			list.UnselectAll()
+			list.Refresh()
+			viewer.SetText("")
+		})
+	}
+	folderSelect.OnChanged = loadFolder
+
+	list.OnSelected = func(id widget.ListItemID) {
+		folder, uid := folderSelect.Selected, envelopes[id].UID
+		var raw string
+		g.withIMAP(func(c *imapClient) error {
+			if _, err := c.examine(folder); err != nil {
+				return err
+			}
+			var err error
+			raw, err = c.body(uid)
+			return err
+		}, func() {
+			current = raw
+			viewer.SetText(plainTextMessage(raw))
+		})
+	}
+
+	connectButton := widget.NewButton("Connect", func() {
+		var names []string
+		g.withIMAP(func(c *imapClient) error {
+			var err error
+			names, err = c.folders()
+			return err
+		}, func() {
+			folderSelect.Options = names
+			folderSelect.Refresh()
+			for _, n := range names {
+				if strings.EqualFold(n, "INBOX") {
+					folderSelect.SetSelected(n)
+				}
+			}
+		})
+	})
+	refreshButton := widget.NewButton("Refresh", func() {
+		if folderSelect.Selected == "" {
+			dialog.ShowError(fmt.Errorf("No folder selected"), g.window)
+			return
+		}
+		loadFolder(folderSelect.Selected)
+	})
+	replyButton := widget.NewButton("Reply", func() {
+		if current == "" {
+			dialog.ShowError(fmt.Errorf("No message selected"), g.window)
+			return
+		}
+		from := g.replyFrom()
+		text, err := replyText(current, from)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to build reply: %v", err), g.window)
+			return
+		}
+		g.messageEnt.SetText(text)
+		g.statusLabel.SetText(replyStatus(from))
+		g.tabs.SelectIndex(0)
+	})
+
+	return container.NewBorder(
+		nil, nil,
+		container.NewBorder(
+			container.NewVBox(folderSelect, container.NewHBox(connectButton, refreshButton, replyButton)),
+			nil, nil, nil,
+			list,
+		),
+		nil,
+		container.NewScroll(viewer),
+	)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isControl reports whether c is an ASCII control character other than
+// tab. CR and LF end a protocol line, NUL separates SASL PLAIN fields,
+// and the rest have no business in a header or command.
+func isControl(c byte) bool {
+	return c < 0x20 && c != '\t' || c == 0x7f
+}
+
+// checkLine returns an error when s could not be sent as part of one
+// protocol line or header value. what names the value in the message.
+func checkLine(what, s string) error {
+	for i := 0; i < len(s); i++ {
+		if isControl(s[i]) {
+			return fmt.Errorf("%s contains a control character (0x%02x)", what, s[i])
+		}
+	}
+	return nil
+}
+
+// checkSecret is checkLine for a password held in a byte slice; the
+// error does not reveal which character was found.
+func checkSecret(what string, b []byte) error {
+	for _, c := range b {
+		if isControl(c) {
+			return fmt.Errorf("%s contains a control character", what)
+		}
+	}
+	return nil
+}
+
+// commandLine formats a protocol command and checks that its arguments
+// did not add line breaks or other control characters to it.
+func commandLine(format string, args ...interface{}) (string, error) {
+	line := fmt.Sprintf(format, args...)
+	if err := checkLine("command", line); err != nil {
+		verb := strings.Fields(format)
+		if len(verb) > 0 {
+			return "", fmt.Errorf("%s: %v", verb[0], err)
+		}
+		return "", err
+	}
+	return line, nil
+}
+
+// checkHeaderBlock checks the header of a CRLF message before it is
+// handed to a server: every line must be a field or a continuation, and
+// none may carry a bare CR, LF or other control character that would
+// let a value start a header or command of its own.
+func checkHeaderBlock(raw string) error {
+	header, _ := splitMessage(raw)
+	for i, line := range strings.Split(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if err := checkLine("header line "+fmt.Sprint(i+1), line); err != nil {
+			return err
+		}
+		if line[0] != ' ' && line[0] != '\t' && !strings.Contains(line, ":") {
+			return fmt.Errorf("header line %d is not a header field: %q", i+1, line)
+		}
+	}
+	return nil
+}
+
+// singleLineSettings lists the profile settings that end up in protocol
+// lines, headers or command arguments, with the labels shown for them.
+func (g *GUI) singleLineSettings() [][2]string {
+	return [][2]string{
+		{"SMTP Host", g.hostEnt.Text},
+		{"SMTP Port", g.portEnt.Text},
+		{"Username", g.usernameEnt.Text},
+		{"Password", g.passwordEnt.Text},
+		{"SOCKS5 Port", g.socksPortEnt.Text},
+		{"Hashcash Bits", g.hashcashBitsEntry.Text},
+		{"Hashcash Receiver", g.hashcashReceiverEntry.Text},
+		{"NNTP Host", g.nntpHostEnt.Text},
+		{"NNTP Port", g.nntpPortEnt.Text},
+		{"NNTP Username", g.nntpUsernameEnt.Text},
+		{"NNTP Password", g.nntpPasswordEnt.Text},
+		{"Reader Group", g.readerGroupEnt.Text},
+		{"POP3 Host", g.pop3HostEnt.Text},
+		{"POP3 Port", g.pop3PortEnt.Text},
+		{"POP3 Username", g.pop3UsernameEnt.Text},
+		{"POP3 Password", g.pop3PasswordEnt.Text},
+		{"IMAP Host", g.imapHostEnt.Text},
+		{"IMAP Port", g.imapPortEnt.Text},
+		{"IMAP Username", g.imapUsernameEnt.Text},
+		{"IMAP Password", g.imapPasswordEnt.Text},
+		{"Message-ID domain", g.messageIDDomainEntry.Text},
+		{"Date jitter", g.dateJitterEntry.Text},
+	}
+}
+
+// checkSettings rejects profile settings that contain line breaks or
+// control characters, naming the first offending field, and a date
+// jitter outside the range policyDate accepts.
+func (g *GUI) checkSettings() error {
+	for _, s := range g.singleLineSettings() {
+		if err := checkLine(s[0], s[1]); err != nil {
+			return err
+		}
+	}
+	_, err := parseDateJitter(g.dateJitterEntry.Text)
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// injections are values that would end a protocol line or header early,
+// or carry bytes no line may contain.
+var injections = []struct {
+	name  string
+	value string
+}{
+	{"CR", "USER a\rPASS x"},
+	{"LF", "USER a\nPASS x"},
+	{"CRLF", "USER a\r\nPASS x"},
+	{"NUL", "user\x00pass"},
+	{"DEL", "user\x7f"},
+}
+
+func TestCheckLine(t *testing.T) {
+	for _, tt := range injections {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkLine("Username", tt.value); err == nil {
+				t.Errorf("checkLine accepted %q", tt.value)
+			}
+		})
+	}
+	for _, ok := range []string{"", "alice", "smtp.example.org", "with\ttab", "Grüße"} {
+		if err := checkLine("value", ok); err != nil {
+			t.Errorf("checkLine(%q) = %v", ok, err)
+		}
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	for _, tt := range injections {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSecret("Password", []byte(tt.value))
+			if err == nil {
+				t.Fatalf("checkSecret accepted %q", tt.value)
+			}
+			if strings.Contains(err.Error(), "0x") {
+				t.Errorf("checkSecret error reveals the character: %v", err)
+			}
+		})
+	}
+	if err := checkSecret("Password", []byte("correct horse")); err != nil {
+		t.Errorf("checkSecret rejected a plain password: %v", err)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	for _, tt := range injections {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := commandLine("USER %s", tt.value)
+			if err == nil {
+				t.Fatalf("commandLine returned %q", line)
+			}
+			if !strings.HasPrefix(err.Error(), "USER: ") {
+				t.Errorf("error %q does not name the command", err)
+			}
+		})
+	}
+	line, err := commandLine("GROUP %s", "alt.anonymous.messages")
+	if err != nil || line != "GROUP alt.anonymous.messages" {
+		t.Errorf("commandLine = %q, %v", line, err)
+	}
+}
+
+func TestCheckHeaderBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		ok   bool
+	}{
+		{"plain", "From: a@example.org\r\nTo: b@example.org\r\nSubject: hi\r\n\r\nbody\r\n", true},
+		{"folded", "From: a@example.org\r\nSubject: a long\r\n subject\r\n\tcontinued\r\n\r\nbody\r\n", true},
+		{"body may hold anything", "From: a@example.org\r\n\r\nline\nbare\rbreaks\x00\r\n", true},
+		{"Bcc typed as its own field", "From: a@example.org\r\nSubject: hi\r\nBcc: spy@example.org\r\n\r\nbody\r\n", true},
+		{"Bcc injected by bare LF", "From: a@example.org\r\nSubject: hi\nBcc: spy@example.org\r\n\r\nbody\r\n", false},
+		{"bare LF in value", "From: a@example.org\r\nSubject: one\ntwo\r\n\r\nbody\r\n", false},
+		{"bare CR in value", "From: a@example.org\r\nSubject: one\rtwo\r\n\r\nbody\r\n", false},
+		{"NUL in value", "From: a@example.org\r\nSubject: one\x00\r\n\r\nbody\r\n", false},
+		{"not a field", "From: a@example.org\r\nnot a header\r\n\r\nbody\r\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkHeaderBlock(tt.raw)
+			if tt.ok && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("accepted")
+			}
+		})
+	}
+}
+
+func TestMintHashcashArguments(t *testing.T) {
+	tests := []struct {
+		name     string
+		bits     string
+		receiver string
+	}{
+		{"receiver is an option", "20", "-o/tmp/x"},
+		{"receiver is a long option", "20", "--help"},
+		{"receiver with CRLF", "20", "nym@example.org\r\nX-Evil: 1"},
+		{"bits not a number", "20 -o", "nym@example.org"},
+		{"bits empty", "", "nym@example.org"},
+		{"bits an option", "-z", "nym@example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stamp, err := mintHashcash(tt.bits, tt.receiver); err == nil {
+				t.Errorf("mintHashcash(%q, %q) = %q", tt.bits, tt.receiver, stamp)
+			}
+		})
+	}
+}
+
+func TestAttachmentNameParams(t *testing.T) {
+	part := attachmentPart(attachment{Name: "report.pdf\r\nBcc: spy@example.org", Data: []byte("x")})
+	header, _ := splitMessage(part)
+	if err := checkHeaderBlock(header + "\r\n\r\n"); err != nil {
+		t.Fatalf("attachment header is unsafe: %v\n%s", err, header)
+	}
+	if strings.Contains(header, "name=\"") {
+		t.Errorf("control characters were quoted into a parameter:\n%s", header)
+	}
+	if !strings.Contains(header, "%0D%0A") {
+		t.Errorf("CRLF was not percent-encoded:\n%s", header)
+	}
+}
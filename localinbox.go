@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	localFolderMail   = "Mail (POP3)"
+	localFolderUsenet = "Usenet"
+)
+
+var localFolders = []string{localFolderMail, localFolderUsenet}
+
+// localMessages returns the paths of the messages stored in folder.
+func (g *GUI) localMessages(folder string) ([]string, error) {
+	if folder == localFolderUsenet {
+		dir, err := newsInboxPath()
+		if err != nil {
+			return nil, err
+		}
+		names, err := listNewsInbox()
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, name)
+		}
+		return paths, nil
+	}
+	box, err := g.mailbox()
+	if err != nil {
+		return nil, err
+	}
+	return box.messages()
+}
+
+// messageSummary is the list label for a stored message: its Subject, or
+// the file name when there is none.
+func messageSummary(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	header, _ := splitMessage(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	for _, line := range strings.Split(header, "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "subject") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return filepath.Base(path)
+}
+
+// buildLocalInboxTab lists the mail and Usenet articles fetched into the
+// local stores and shows the selected one.
+func (g *GUI) buildLocalInboxTab() *fyne.Container {
+	var paths []string
+	viewer := widget.NewMultiLineEntry()
+	viewer.TextStyle = fyne.TextStyle{Monospace: true}
+	viewer.Wrapping = fyne.TextWrapOff
+
+	list := widget.NewList(
+		func() int { return len(paths) },
+		func() fyne.CanvasObject { return widget.NewLabel("message") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(messageSummary(paths[id]))
+		},
+	)
+	folderSelect := widget.NewSelect(localFolders, nil)
+	refresh := func() {
+		var err error
+		paths, err = g.localMessages(folderSelect.Selected)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to read inbox: %v", err), g.window)
+		}
+		list.UnselectAll()
+		list.Refresh()
+		viewer.SetText("")
+	}
+	folderSelect.OnChanged = func(string) { refresh() }
+	list.OnSelected = func(id widget.ListItemID) {
+		data, err := os.ReadFile(paths[id])
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to read message: %v", err), g.window)
+			return
+		}
+		viewer.SetText(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	}
+
+	fetchButton := widget.NewButton("Fetch New", func() {
+		if folderSelect.Selected == localFolderUsenet {
+			g.fetchNews(refresh)
+		} else {
+			g.fetchPOP3(refresh)
+		}
+	})
+	controls := container.NewHBox(fetchButton, widget.NewButton("Refresh", refresh))
+	folderSelect.SetSelected(localFolderMail)
+
+	return container.NewBorder(
+		nil, nil,
+		container.NewBorder(folderSelect, controls, nil, nil, list),
+		nil,
+		container.NewScroll(viewer),
+	)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maildir is a Maildir folder on disk (tmp, new and cur).
+type maildir string
+
+func (m maildir) create() error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(string(m), sub), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueName returns a Maildir file name. The usual host part is a fixed
+// string so stored mail does not record the machine's host name.
+func (m maildir) uniqueName() (string, error) {
+	r := make([]byte, 8)
+	if _, err := rand.Read(r); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return fmt.Sprintf("%d.M%dR%s.mmg", now.Unix(), now.Nanosecond()/1000, hex.EncodeToString(r)), nil
+}
+
+// deliver writes a message into tmp and moves it into new, as the
+// Maildir delivery protocol requires.
+func (m maildir) deliver(data []byte) (string, error) {
+	if err := m.create(); err != nil {
+		return "", err
+	}
+	name, err := m.uniqueName()
+	if err != nil {
+		return "", err
+	}
+	tmp := filepath.Join(string(m), "tmp", name)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	path := filepath.Join(string(m), "new", name)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// messages returns the paths of the messages in new and cur, newest
+// first.
+func (m maildir) messages() ([]string, error) {
+	var paths []string
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(string(m), sub))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				paths = append(paths, filepath.Join(string(m), sub, e.Name()))
+			}
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) > filepath.Base(paths[j])
+	})
+	return paths, nil
+}
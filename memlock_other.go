@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package main
+
+func lockMemory(b []byte) {}
+
+func unlockMemory(b []byte) {}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// lockMemory keeps b out of swap. Failure (e.g. RLIMIT_MEMLOCK) is not
+// fatal; the buffer is still zeroed when released.
+func lockMemory(b []byte) {
+	if len(b) > 0 {
+		unix.Mlock(b)
+	}
+}
+
+func unlockMemory(b []byte) {
+	if len(b) > 0 {
+		unix.Munlock(b)
+	}
+}
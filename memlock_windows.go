@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockMemory keeps b out of the page file. Failure is not fatal; the
+// buffer is still zeroed when released.
+func lockMemory(b []byte) {
+	if len(b) > 0 {
+		windows.VirtualLock(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+	}
+}
+
+func unlockMemory(b []byte) {
+	if len(b) > 0 {
+		windows.VirtualUnlock(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+	}
+}
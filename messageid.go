@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	messageIDClassic = "classic"
+	messageIDUUID    = "uuid"
+	messageIDRandom  = "random"
+	messageIDGmail   = "gmail"
+)
+
+var messageIDStrategies = []string{messageIDClassic, messageIDUUID, messageIDRandom, messageIDGmail}
+
+// messageIDProfileDefault is offered in the template editor to use the
+// profile's strategy.
+const messageIDProfileDefault = "profile default"
+
+// randomString returns n characters drawn from alphabet with crypto/rand.
+func randomString(alphabet string, n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[k.Int64()]
+	}
+	return string(b), nil
+}
+
+// randomUUID returns a version 4 UUID.
+func randomUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// newMessageID builds a Message-ID with the given strategy. classic is
+// mmg's original format, which embeds the send time. uuid looks like the
+// IDs Thunderbird and Apple Mail write, random has no structure at all,
+// and gmail mimics the IDs of the Gmail web interface. domain is the
+// right-hand side for uuid and random and must be set for them.
+func newMessageID(strategy, domain string) (string, error) {
+	domain = strings.Trim(strings.TrimSpace(domain), "<>@")
+	var local string
+	var err error
+	switch strategy {
+	case messageIDUUID, messageIDRandom:
+		if domain == "" {
+			return "", fmt.Errorf("the %s strategy needs a domain", strategy)
+		}
+		if strategy == messageIDUUID {
+			local, err = randomUUID()
+		} else {
+			local, err = randomString("abcdefghijklmnopqrstuvwxyz0123456789", 24)
+		}
+	case messageIDGmail:
+		const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-+="
+		local, err = randomString(alphabet, 49)
+		local, domain = "CA"+local, "mail.gmail.com"
+	default:
+		return generateMessageID(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "<" + local + "@" + domain + ">", nil
+}
+
+// messageIDSettings returns the strategy and domain for an automatic
+// Message-ID: the selected template's when it sets one, otherwise the
+// profile's.
+func (g *GUI) messageIDSettings() (strategy, domain string) {
+	strategy, domain = g.messageIDSelect.Selected, g.messageIDDomainEntry.Text
+	if g.selectedTemplate >= 0 && g.selectedTemplate < len(g.templates) {
+		t := g.templates[g.selectedTemplate]
+		if validChoice(messageIDStrategies, t.MessageID) {
+			strategy = t.MessageID
+		}
+		if strings.TrimSpace(t.MessageIDDomain) != "" {
+			domain = t.MessageIDDomain
+		}
+	}
+	return strategy, domain
+}
+
+// autoMessageID builds the automatic Message-ID. uuid and random refuse
+// to run without a configured domain rather than borrow the From
+// address's, which would tie the Message-ID to the sender's provider.
+func (g *GUI) autoMessageID() (string, error) {
+	strategy, domain := g.messageIDSettings()
+	if (strategy == messageIDUUID || strategy == messageIDRandom) && strings.TrimSpace(domain) == "" {
+		return "", fmt.Errorf("Set a Message-ID domain in the profile or template for the %s strategy", strategy)
+	}
+	id, err := newMessageID(strategy, domain)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate Message-ID: %v", err)
+	}
+	return id, nil
+}
+
+// newTemplateMessageIDSelect is the per-template strategy choice.
+func newTemplateMessageIDSelect() *widget.Select {
+	s := widget.NewSelect(append([]string{messageIDProfileDefault}, messageIDStrategies...), nil)
+	s.SetSelected(messageIDProfileDefault)
+	return s
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewMessageIDShape(t *testing.T) {
+	tests := []struct {
+		strategy, domain string
+		shape            *regexp.Regexp
+	}{
+		{messageIDClassic, "", regexp.MustCompile(`^<[a-z0-9]{10}\.\d+@[a-z]{5}\.[a-z]{2}>$`)},
+		{messageIDUUID, "example.org", regexp.MustCompile(`^<[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}@example\.org>$`)},
+		{messageIDUUID, " <@example.org> ", regexp.MustCompile(`^<[0-9a-f-]{36}@example\.org>$`)},
+		{messageIDRandom, "example.org", regexp.MustCompile(`^<[a-z0-9]{24}@example\.org>$`)},
+		{messageIDGmail, "", regexp.MustCompile(`^<CA[A-Za-z0-9_+=-]{49}@mail\.gmail\.com>$`)},
+		{messageIDGmail, "example.org", regexp.MustCompile(`^<CA[A-Za-z0-9_+=-]{49}@mail\.gmail\.com>$`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy+" "+tt.domain, func(t *testing.T) {
+			first, err := newMessageID(tt.strategy, tt.domain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.shape.MatchString(first) {
+				t.Errorf("newMessageID(%q, %q) = %q, want %v", tt.strategy, tt.domain, first, tt.shape)
+			}
+			second, err := newMessageID(tt.strategy, tt.domain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == second {
+				t.Errorf("newMessageID(%q) returned %q twice", tt.strategy, first)
+			}
+		})
+	}
+}
+
+func TestNewMessageIDNoTimestamp(t *testing.T) {
+	for _, strategy := range []string{messageIDUUID, messageIDRandom, messageIDGmail} {
+		t.Run(strategy, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				before := time.Now().Unix()
+				id, err := newMessageID(strategy, "example.org")
+				if err != nil {
+					t.Fatal(err)
+				}
+				for s := before; s <= time.Now().Unix(); s++ {
+					if strings.Contains(id, strconv.FormatInt(s, 10)) {
+						t.Fatalf("%q contains the send time %d", id, s)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewMessageIDNeedsDomain(t *testing.T) {
+	for _, strategy := range []string{messageIDUUID, messageIDRandom} {
+		for _, domain := range []string{"", "  ", "<@>"} {
+			t.Run(strategy+" "+domain, func(t *testing.T) {
+				id, err := newMessageID(strategy, domain)
+				if err == nil {
+					t.Fatalf("newMessageID(%q, %q) = %q, want an error", strategy, domain, id)
+				}
+				if !strings.Contains(err.Error(), "needs a domain") {
+					t.Errorf("error %q does not ask for a domain", err)
+				}
+			})
+		}
+	}
+}
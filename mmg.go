@@ -3,7 +3,6 @@ package main
 import (
     "crypto/rand"
     "crypto/tls"
-    "encoding/hex"
     "encoding/json"
     "fmt"
     "math/big"
@@ -13,7 +12,9 @@ import (
     "os/exec"
     "path/filepath"
     "runtime"
+    "strconv"
     "strings"
+    "sync/atomic"
     "time"
 
     "gopkg.in/yaml.v2"
@@ -27,8 +28,6 @@ import (
     "fyne.io/fyne/v2/widget"
 
     "golang.org/x/crypto/argon2"
-    "golang.org/x/crypto/chacha20"
-    "golang.org/x/crypto/sha3"
     "golang.org/x/net/proxy"
 
     "github.com/atotto/clipboard"
@@ -41,6 +40,24 @@ const (
     configExtension   = ".yaml"
 )
 
+func appConfigDir() (string, error) {
+    configPath, err := os.UserConfigDir()
+    if err != nil {
+        return "", err
+    }
+    appDir := filepath.Join(configPath, configDir)
+    return appDir, os.MkdirAll(appDir, 0755)
+}
+
+func validChoice(choices []string, value string) bool {
+    for _, c := range choices {
+        if c == value {
+            return true
+        }
+    }
+    return false
+}
+
 type Config struct {
     SMTPHost         string `yaml:"smtp_host"`
     SMTPPort         string `yaml:"smtp_port"`
@@ -48,17 +65,49 @@ type Config struct {
     Password         string `yaml:"password"`
     SocksPort        string `yaml:"socks_port"`
     EsubKey          string `yaml:"esub_key"`
+    EsubScheme       string `yaml:"esub_scheme"`
     HashcashBits     string `yaml:"hashcash_bits"`
     HashcashReceiver string `yaml:"hashcash_receiver"`
     Theme            string `yaml:"theme"`
     OmitAutoHeaders  bool   `yaml:"omit_auto_headers"`
+    ClipboardClear   string `yaml:"clipboard_clear"`
+    PGPMode          string `yaml:"pgp_mode"`
+    PGPFormat        string `yaml:"pgp_format"`
+    Mail2News        []string `yaml:"mail2news_gateways"`
+    Mail2NewsAll     bool   `yaml:"mail2news_all"`
+    UsenetRoute      string `yaml:"usenet_route"`
+    NNTPHost         string `yaml:"nntp_host"`
+    NNTPPort         string `yaml:"nntp_port"`
+    NNTPSecurity     string `yaml:"nntp_security"`
+    NNTPUsername     string `yaml:"nntp_username"`
+    NNTPPassword     string `yaml:"nntp_password"`
+    ReaderGroup      string `yaml:"reader_group"`
+    POP3Host         string `yaml:"pop3_host"`
+    POP3Port         string `yaml:"pop3_port"`
+    POP3Security     string `yaml:"pop3_security"`
+    POP3Username     string `yaml:"pop3_username"`
+    POP3Password     string `yaml:"pop3_password"`
+    POP3Delete       bool   `yaml:"pop3_delete"`
+    IMAPHost         string `yaml:"imap_host"`
+    IMAPPort         string `yaml:"imap_port"`
+    IMAPSecurity     string `yaml:"imap_security"`
+    IMAPUsername     string `yaml:"imap_username"`
+    IMAPPassword     string `yaml:"imap_password"`
+    HeaderLint       string `yaml:"header_lint"`
+    HeaderOrder      string `yaml:"header_order"`
+    DatePolicy       string `yaml:"date_policy"`
+    DateJitter       string `yaml:"date_jitter"`
+    MessageID        string `yaml:"message_id"`
+    MessageIDDomain  string `yaml:"message_id_domain"`
 }
 
 type Template struct {
-    Name        string `json:"name"`
-    Headers     string `json:"headers"`
-    Body        string `json:"body"`
-    Description string `json:"description"`
+    Name            string `json:"name"`
+    Headers         string `json:"headers"`
+    Body            string `json:"body"`
+    Description     string `json:"description"`
+    MessageID       string `json:"message_id,omitempty"`
+    MessageIDDomain string `json:"message_id_domain,omitempty"`
 }
 
 type GUI struct {
@@ -84,13 +133,70 @@ type GUI struct {
     themeEntry       *widget.Entry
     encodeMIMESubjectEntry *widget.Entry
     esubKeyEntry        *widget.Entry
+    esubSchemeSelect    *widget.Select
     hashcashBitsEntry   *widget.Entry
     hashcashReceiverEntry *widget.Entry
     omitHeadersCheck    *widget.Check
+    encryptConfigCheck  *widget.Check
+    encryptTemplatesCheck *widget.Check
+    encryptTemplates    bool
+    templatesLocked     bool
+    masterPassphrase    []byte
+    clipboardClearEntry *widget.Entry
+    clipboardGen        atomic.Uint64
+    pgpModeSelect       *widget.Select
+    pgpFormatSelect     *widget.Select
+    remailers           []remailerEntry
+    remailerList        *widget.List
+    selectedRemailer    int
+    lastRemailerChain   string
+    nyms                []nymAccount
+    nymsLoaded          bool
+    nymList             *widget.List
+    selectedNym         int
+    nymStatusLabel      *widget.Label
+    replyBlocks         []replyBlock
+    replyBlocksLoaded   bool
+    replyBlockList      *widget.List
+    selectedReplyBlock  int
+    usenetCheck         *widget.Check
+    mail2newsEntry      *widget.Entry
+    mail2newsAllCheck   *widget.Check
+    usenetRouteSelect   *widget.Select
+    nntpHostEnt         *widget.Entry
+    nntpPortEnt         *widget.Entry
+    nntpSecuritySelect  *widget.Select
+    nntpUsernameEnt     *widget.Entry
+    nntpPasswordEnt     *widget.Entry
+    readerGroupEnt      *widget.Entry
+    pop3HostEnt         *widget.Entry
+    pop3PortEnt         *widget.Entry
+    pop3SecuritySelect  *widget.Select
+    pop3UsernameEnt     *widget.Entry
+    pop3PasswordEnt     *widget.Entry
+    pop3DeleteCheck     *widget.Check
+    imapHostEnt         *widget.Entry
+    imapPortEnt         *widget.Entry
+    imapSecuritySelect  *widget.Select
+    imapUsernameEnt     *widget.Entry
+    imapPasswordEnt     *widget.Entry
+    tabs                *container.AppTabs
+    attachments         []attachment
+    attachmentList      *widget.List
+    attachmentSizeLabel *widget.Label
+    sendUncleanCheck    *widget.Check
+    headerLintSelect    *widget.Select
+    headerOrderSelect   *widget.Select
+    datePolicySelect    *widget.Select
+    dateJitterEntry     *widget.Entry
+    messageIDSelect     *widget.Select
+    messageIDDomainEntry *widget.Entry
+    templateMessageIDSelect *widget.Select
+    templateMessageIDDomain *widget.Entry
+    smtpSizeLimit       atomic.Int64
+    pendingUnlock       []func()
 }
 
-var fixedSalt = []byte("61546a8cbbe0957d")
-
 const (
 	argon2Time    = 1
 	argon2Memory  = 64 * 1024
@@ -98,74 +204,11 @@ const (
 	argon2KeyLen  = 32
 )
 
-func deriveArgon2Key(password string, salt []byte) []byte {
+func deriveArgon2Key(password []byte, salt []byte) []byte {
 	if len(salt) != 16 {
-		panic("Fixed salt must be exactly 16 bytes long")
+		panic("Salt must be exactly 16 bytes long")
 	}
-	return argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
-}
-
-type esub struct {
-	key      string
-	subject  string
-}
-
-func (e *esub) deriveKey() []byte {
-	salt := []byte("fixed-salt-1234")
-	key := argon2.IDKey(
-		[]byte(e.key),
-		salt,
-		3,
-		64*1024,
-		4,
-		32,
-	)
-	return key
-}
-
-func (e *esub) esubtest() bool {
-	if len(e.subject) != 48 {
-		return false
-	}
-
-	esubBytes, err := hex.DecodeString(e.subject)
-	if err != nil || len(esubBytes) != 24 {
-		return false
-	}
-
-	nonce := esubBytes[:12]
-	receivedCiphertext := esubBytes[12:]
-
-	key := e.deriveKey()
-	cipher, err := chacha20.NewUnauthenticatedCipher(key, nonce)
-	if err != nil {
-		return false
-	}
-
-	textHash := sha3.Sum256([]byte("text"))
-	expectedCiphertext := make([]byte, 12)
-	cipher.XORKeyStream(expectedCiphertext, textHash[:12])
-
-	return hex.EncodeToString(expectedCiphertext) == hex.EncodeToString(receivedCiphertext)
-}
-
-func (e *esub) esubgen() string {
-	nonce := make([]byte, 12)
-	if _, err := rand.Read(nonce); err != nil {
-		panic(err)
-	}
-
-	key := e.deriveKey()
-	cipher, err := chacha20.NewUnauthenticatedCipher(key, nonce)
-	if err != nil {
-		panic(err)
-	}
-
-	textHash := sha3.Sum256([]byte("text"))
-	ciphertext := make([]byte, 12)
-	cipher.XORKeyStream(ciphertext, textHash[:12])
-
-	return hex.EncodeToString(append(nonce, ciphertext...))
+	return argon2.IDKey(password, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
 }
 
 type encodeMIMESubject struct {
@@ -199,10 +242,18 @@ func (e encodeMIMESubject) encodeMIMESubject() string {
 func (g *GUI) showEsubDialog() {
     keyEntry := widget.NewEntry()
     keyEntry.SetText(g.esubKeyEntry.Text)
+    textEntry := widget.NewEntry()
+    textEntry.SetPlaceHolder(esubDefaultText)
+    schemeSelect := widget.NewSelect(esubSchemes, nil)
+    schemeSelect.SetSelected(g.esubSchemeSelect.Selected)
 
     dialogContent := container.NewVBox(
         widget.NewLabel("Enter your key:"),
         keyEntry,
+        widget.NewLabel("Subject text (optional):"),
+        textEntry,
+        widget.NewLabel("Scheme:"),
+        schemeSelect,
     )
 
     dialog.NewCustomConfirm(
@@ -216,9 +267,14 @@ func (g *GUI) showEsubDialog() {
                     dialog.ShowError(fmt.Errorf("Key cannot be empty"), g.window)
                     return
                 }
-                e := esub{key: keyEntry.Text}
-                esubStr := e.esubgen()
-                err := clipboard.WriteAll(esubStr)
+                e := esub{key: secretBytes(keyEntry.Text), text: textEntry.Text, scheme: schemeSelect.Selected}
+                esubStr, err := e.esubgen()
+                wipeBytes(e.key)
+                if err != nil {
+                    dialog.ShowError(fmt.Errorf("Failed to generate esub: %v", err), g.window)
+                    return
+                }
+                err = g.copyToClipboard(esubStr)
                 if err != nil {
                     dialog.ShowError(fmt.Errorf("Failed to copy to clipboard: %v", err), g.window)
                     return
@@ -232,6 +288,75 @@ func (g *GUI) showEsubDialog() {
     ).Show()
 }
 
+func (g *GUI) showEsubTestDialog() {
+    keyEntry := widget.NewEntry()
+    keyEntry.SetText(g.esubKeyEntry.Text)
+    textEntry := widget.NewEntry()
+    textEntry.SetPlaceHolder(esubDefaultText)
+    subjectEntry := widget.NewMultiLineEntry()
+    subjectEntry.SetPlaceHolder("One esub (48 hex characters) per line")
+    schemeSelect := widget.NewSelect(esubSchemes, nil)
+    schemeSelect.SetSelected(g.esubSchemeSelect.Selected)
+
+    dialogContent := container.NewVBox(
+        widget.NewLabel("Enter your key:"),
+        keyEntry,
+        widget.NewLabel("Subject text (optional):"),
+        textEntry,
+        widget.NewLabel("esubs to test:"),
+        subjectEntry,
+        widget.NewLabel("Scheme:"),
+        schemeSelect,
+    )
+
+    dialog.NewCustomConfirm(
+        "esub Tester",
+        "Test",
+        "Cancel",
+        dialogContent,
+        func(confirmed bool) {
+            if confirmed {
+                if keyEntry.Text == "" {
+                    dialog.ShowError(fmt.Errorf("Key cannot be empty"), g.window)
+                    return
+                }
+                var subjects []string
+                for _, line := range strings.Split(subjectEntry.Text, "\n") {
+                    if line = strings.TrimSpace(line); line != "" {
+                        subjects = append(subjects, line)
+                    }
+                }
+                if len(subjects) == 0 {
+                    dialog.ShowError(fmt.Errorf("No esub to test"), g.window)
+                    return
+                }
+                e := esub{key: secretBytes(keyEntry.Text), text: textEntry.Text, scheme: schemeSelect.Selected}
+                g.statusLabel.SetText(fmt.Sprintf("Testing %d esubs...", len(subjects)))
+                go func() {
+                    defer wipeBytes(e.key)
+                    var matches []string
+                    for i, ok := range e.esubtestBatch(subjects) {
+                        if ok {
+                            matches = append(matches, fmt.Sprintf("line %d: %s", i+1, subjects[i]))
+                        }
+                    }
+                    fyne.Do(func() {
+                        if len(matches) == 0 {
+                            g.statusLabel.SetText(fmt.Sprintf("No match among %d esubs (%s).", len(subjects), e.scheme))
+                            return
+                        }
+                        g.statusLabel.SetText(fmt.Sprintf("%d of %d esubs match (%s):\n%s",
+                            len(matches), len(subjects), e.scheme, strings.Join(matches, "\n")))
+                    })
+                }()
+            } else {
+                g.statusLabel.SetText("esub test cancelled.")
+            }
+        },
+        g.window,
+    ).Show()
+}
+
 func (g *GUI) showHashcashDialog() {
     bitsEntry := widget.NewEntry()
     bitsEntry.SetText(g.hashcashBitsEntry.Text)
@@ -263,13 +388,12 @@ func (g *GUI) showHashcashDialog() {
                         return
                     }
                 }
-                cmd := exec.Command("hashcash", "-mb"+bitsEntry.Text, "-z", "12", "-r", receiverEntry.Text)
-                out, err := cmd.Output()
+                out, err := mintHashcash(bitsEntry.Text, receiverEntry.Text)
                 if err != nil {
                     dialog.ShowError(fmt.Errorf("Failed to generate hashcash: %v", err), g.window)
                     return
                 }
-                err = clipboard.WriteAll(string(out))
+                err = g.copyToClipboard(out)
                 if err != nil {
                     dialog.ShowError(fmt.Errorf("Failed to copy to clipboard: %v", err), g.window)
                     return
@@ -283,6 +407,26 @@ func (g *GUI) showHashcashDialog() {
     ).Show()
 }
 
+// mintHashcash runs the hashcash tool and returns the stamp it prints.
+func mintHashcash(bits, receiver string) (string, error) {
+    if _, err := strconv.Atoi(strings.TrimSpace(bits)); err != nil {
+        return "", fmt.Errorf("bits must be a number")
+    }
+    if err := checkLine("receiver", receiver); err != nil {
+        return "", err
+    }
+    if strings.HasPrefix(receiver, "-") {
+        return "", fmt.Errorf("receiver must not start with '-'")
+    }
+    bits = strings.TrimSpace(bits)
+    cmd := exec.Command("hashcash", "-mb"+bits, "-z", "12", "-r", receiver)
+    out, err := cmd.Output()
+    if err != nil {
+        return "", err
+    }
+    return string(out), nil
+}
+
 func (g *GUI) showencodeMIMESubjectDialog() {
     dialogContent := container.NewVBox(
         widget.NewLabel("Enter your Subject:"),
@@ -300,9 +444,13 @@ func (g *GUI) showencodeMIMESubjectDialog() {
                     dialog.ShowError(fmt.Errorf("Subject cannot be empty"), g.window)
                     return
                 }
+                if err := checkLine("Subject", g.encodeMIMESubjectEntry.Text); err != nil {
+                    dialog.ShowError(err, g.window)
+                    return
+                }
                 s := encodeMIMESubject{Subject: g.encodeMIMESubjectEntry.Text}
                 encodeMIMESubjectStr := s.encodeMIMESubject()
-                err := clipboard.WriteAll(encodeMIMESubjectStr)
+                err := g.copyToClipboard(encodeMIMESubjectStr)
                 if err != nil {
                     dialog.ShowError(fmt.Errorf("Failed to copy to clipboard: %v", err), g.window)
                     return
@@ -320,13 +468,19 @@ func (g *GUI) createMiscMenu() *fyne.Menu {
     esubItem := fyne.NewMenuItem("esub", func() {
         g.showEsubDialog()
     })
+    esubTestItem := fyne.NewMenuItem("esub test", func() {
+        g.showEsubTestDialog()
+    })
     hashcashItem := fyne.NewMenuItem("hashcash", func() {
         g.showHashcashDialog()
     })
     SubjectItem := fyne.NewMenuItem("MIME", func() {
         g.showencodeMIMESubjectDialog()
     })
-    return fyne.NewMenu("Tools", esubItem, hashcashItem, SubjectItem)
+    remailerItem := fyne.NewMenuItem("Remailer Chain", func() {
+        g.showRemailerChainDialog()
+    })
+    return fyne.NewMenu("Tools", esubItem, esubTestItem, hashcashItem, SubjectItem, remailerItem)
 }
 
 func (g *GUI) loadConfig() {
@@ -349,21 +503,117 @@ func (g *GUI) loadConfig() {
         dialog.ShowError(fmt.Errorf("Failed to read config: %v", err), g.window)
         return
     }
+    encrypted := isEncryptedProfile(data)
+    if encrypted {
+        if g.masterPassphrase == nil {
+            g.showUnlockDialog(func(passphrase []byte) error {
+                _, err := openProfile(data, passphrase)
+                return err
+            }, g.loadConfig)
+            return
+        }
+        data, err = openProfile(data, g.masterPassphrase)
+        if err != nil {
+            dialog.ShowError(fmt.Errorf("Failed to decrypt config: %v", err), g.window)
+            return
+        }
+        defer zero(data)
+    }
     var config Config
     if err := yaml.Unmarshal(data, &config); err != nil {
         dialog.ShowError(fmt.Errorf("Failed to parse config: %v", err), g.window)
         return
     }
+    g.encryptConfigCheck.SetChecked(encrypted)
     g.hostEnt.SetText(config.SMTPHost)
     g.portEnt.SetText(config.SMTPPort)
     g.usernameEnt.SetText(config.Username)
     g.passwordEnt.SetText(config.Password)
     g.socksPortEnt.SetText(config.SocksPort)
     g.esubKeyEntry.SetText(config.EsubKey)
+    if validChoice(esubSchemes, config.EsubScheme) {
+        g.esubSchemeSelect.SetSelected(config.EsubScheme)
+    } else {
+        g.esubSchemeSelect.SetSelected(esubSchemeMMG)
+    }
     g.hashcashBitsEntry.SetText(config.HashcashBits)
     g.hashcashReceiverEntry.SetText(config.HashcashReceiver)
     g.themeEntry.SetText(config.Theme)
     g.omitHeadersCheck.SetChecked(config.OmitAutoHeaders) // Neue Zeile
+    g.clipboardClearEntry.SetText(config.ClipboardClear)
+    if validChoice(pgpModes, config.PGPMode) {
+        g.pgpModeSelect.SetSelected(config.PGPMode)
+    } else {
+        g.pgpModeSelect.SetSelected(pgpModeOff)
+    }
+    if validChoice(pgpFormats, config.PGPFormat) {
+        g.pgpFormatSelect.SetSelected(config.PGPFormat)
+    } else {
+        g.pgpFormatSelect.SetSelected(pgpFormatInline)
+    }
+    g.mail2newsEntry.SetText(strings.Join(config.Mail2News, "\n"))
+    g.mail2newsAllCheck.SetChecked(config.Mail2NewsAll)
+    if validChoice(usenetRoutes, config.UsenetRoute) {
+        g.usenetRouteSelect.SetSelected(config.UsenetRoute)
+    } else {
+        g.usenetRouteSelect.SetSelected(usenetRouteMail2News)
+    }
+    g.nntpHostEnt.SetText(config.NNTPHost)
+    g.nntpPortEnt.SetText(config.NNTPPort)
+    if validChoice(nntpSecurities, config.NNTPSecurity) {
+        g.nntpSecuritySelect.SetSelected(config.NNTPSecurity)
+    } else {
+        g.nntpSecuritySelect.SetSelected(nntpSecurityStartTLS)
+    }
+    g.nntpUsernameEnt.SetText(config.NNTPUsername)
+    g.nntpPasswordEnt.SetText(config.NNTPPassword)
+    g.readerGroupEnt.SetText(config.ReaderGroup)
+    g.pop3HostEnt.SetText(config.POP3Host)
+    g.pop3PortEnt.SetText(config.POP3Port)
+    if validChoice(pop3Securities, config.POP3Security) {
+        g.pop3SecuritySelect.SetSelected(config.POP3Security)
+    } else {
+        g.pop3SecuritySelect.SetSelected(pop3SecuritySTLS)
+    }
+    g.pop3UsernameEnt.SetText(config.POP3Username)
+    g.pop3PasswordEnt.SetText(config.POP3Password)
+    g.pop3DeleteCheck.SetChecked(config.POP3Delete)
+    g.imapHostEnt.SetText(config.IMAPHost)
+    g.imapPortEnt.SetText(config.IMAPPort)
+    if validChoice(imapSecurities, config.IMAPSecurity) {
+        g.imapSecuritySelect.SetSelected(config.IMAPSecurity)
+    } else {
+        g.imapSecuritySelect.SetSelected(imapSecurityStartTLS)
+    }
+    g.imapUsernameEnt.SetText(config.IMAPUsername)
+    g.imapPasswordEnt.SetText(config.IMAPPassword)
+    if validChoice(headerLintPolicies, config.HeaderLint) {
+        g.headerLintSelect.SetSelected(config.HeaderLint)
+    } else {
+        g.headerLintSelect.SetSelected(headerLintWarn)
+    }
+    if validChoice(headerOrders, config.HeaderOrder) {
+        g.headerOrderSelect.SetSelected(config.HeaderOrder)
+    } else {
+        g.headerOrderSelect.SetSelected(headerOrderKeep)
+    }
+    if validChoice(datePolicies, config.DatePolicy) {
+        g.datePolicySelect.SetSelected(config.DatePolicy)
+    } else if config.OmitAutoHeaders {
+        // Profiles from before the date policy omitted Date with the
+        // Message-ID; keep doing so now that the checkbox covers only the
+        // Message-ID.
+        g.datePolicySelect.SetSelected(datePolicyOmit)
+    } else {
+        g.datePolicySelect.SetSelected(datePolicyExact)
+    }
+    g.dateJitterEntry.SetText(config.DateJitter)
+    if validChoice(messageIDStrategies, config.MessageID) {
+        g.messageIDSelect.SetSelected(config.MessageID)
+    } else {
+        g.messageIDSelect.SetSelected(messageIDClassic)
+    }
+    g.messageIDDomainEntry.SetText(config.MessageIDDomain)
     
     if config.Theme == "light" {
         g.app.Settings().SetTheme(theme.LightTheme())
@@ -373,6 +623,10 @@ func (g *GUI) loadConfig() {
 }
 
 func (g *GUI) saveConfig() {
+    if err := g.checkSettings(); err != nil {
+        dialog.ShowError(err, g.window)
+        return
+    }
     configPath, err := os.UserConfigDir()
     if err != nil {
         dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
@@ -401,17 +655,60 @@ func (g *GUI) saveConfig() {
         Password:         g.passwordEnt.Text,
         SocksPort:        g.socksPortEnt.Text,
         EsubKey:          g.esubKeyEntry.Text,
+        EsubScheme:       g.esubSchemeSelect.Selected,
         HashcashBits:     g.hashcashBitsEntry.Text,
         HashcashReceiver: g.hashcashReceiverEntry.Text,
         Theme:            themeValue,
         OmitAutoHeaders:  g.omitHeadersCheck.Checked, // Neue Zeile
+        ClipboardClear:   strings.TrimSpace(g.clipboardClearEntry.Text),
+        PGPMode:          g.pgpModeSelect.Selected,
+        PGPFormat:        g.pgpFormatSelect.Selected,
+        Mail2News:        gatewayList(g.mail2newsEntry.Text),
+        Mail2NewsAll:     g.mail2newsAllCheck.Checked,
+        UsenetRoute:      g.usenetRouteSelect.Selected,
+        NNTPHost:         strings.TrimSpace(g.nntpHostEnt.Text),
+        NNTPPort:         strings.TrimSpace(g.nntpPortEnt.Text),
+        NNTPSecurity:     g.nntpSecuritySelect.Selected,
+        NNTPUsername:     g.nntpUsernameEnt.Text,
+        NNTPPassword:     g.nntpPasswordEnt.Text,
+        ReaderGroup:      strings.TrimSpace(g.readerGroupEnt.Text),
+        POP3Host:         strings.TrimSpace(g.pop3HostEnt.Text),
+        POP3Port:         strings.TrimSpace(g.pop3PortEnt.Text),
+        POP3Security:     g.pop3SecuritySelect.Selected,
+        POP3Username:     g.pop3UsernameEnt.Text,
+        POP3Password:     g.pop3PasswordEnt.Text,
+        POP3Delete:       g.pop3DeleteCheck.Checked,
+        IMAPHost:         strings.TrimSpace(g.imapHostEnt.Text),
+        IMAPPort:         strings.TrimSpace(g.imapPortEnt.Text),
+        IMAPSecurity:     g.imapSecuritySelect.Selected,
+        IMAPUsername:     g.imapUsernameEnt.Text,
+        IMAPPassword:     g.imapPasswordEnt.Text,
+        HeaderLint:       g.headerLintSelect.Selected,
+        HeaderOrder:      g.headerOrderSelect.Selected,
+        DatePolicy:       g.datePolicySelect.Selected,
+        DateJitter:       strings.TrimSpace(g.dateJitterEntry.Text),
+        MessageID:        g.messageIDSelect.Selected,
+        MessageIDDomain:  strings.TrimSpace(g.messageIDDomainEntry.Text),
+    }
+    if g.encryptConfigCheck.Checked && g.masterPassphrase == nil {
+        g.showSetPassphraseDialog(g.saveConfig)
+        return
     }
     data, err := yaml.Marshal(&config)
     if err != nil {
         dialog.ShowError(fmt.Errorf("Failed to serialize config: %v", err), g.window)
         return
     }
-    if err := os.WriteFile(configFilePath, data, 0644); err != nil {
+    if g.encryptConfigCheck.Checked {
+        sealed, err := sealProfile(data, g.masterPassphrase)
+        zero(data)
+        if err != nil {
+            dialog.ShowError(fmt.Errorf("Failed to encrypt config: %v", err), g.window)
+            return
+        }
+        data = sealed
+    }
+    if err := writeFileAtomic(configFilePath, data, 0600); err != nil {
         dialog.ShowError(fmt.Errorf("Failed to save config: %v", err), g.window)
         return
     }
@@ -446,7 +743,33 @@ func (g *GUI) loadTemplates() error {
     if err != nil {
         return err
     }
-    return json.Unmarshal(data, &g.templates)
+    g.encryptTemplates = isEncryptedTemplates(data)
+    g.encryptTemplatesCheck.SetChecked(g.encryptTemplates)
+    if g.encryptTemplates {
+        if g.masterPassphrase == nil {
+            g.templatesLocked = true
+            g.showUnlockDialog(func(passphrase []byte) error {
+                _, err := openTemplates(data, passphrase)
+                return err
+            }, func() {
+                if err := g.loadTemplates(); err != nil {
+                    dialog.ShowError(err, g.window)
+                }
+                g.templateList.Refresh()
+            })
+            return nil
+        }
+        data, err = openTemplates(data, g.masterPassphrase)
+        if err != nil {
+            return err
+        }
+        defer zero(data)
+    }
+    if err := json.Unmarshal(data, &g.templates); err != nil {
+        return err
+    }
+    g.templatesLocked = false
+    return nil
 }
 
 func (g *GUI) saveTemplates() error {
@@ -454,12 +777,26 @@ func (g *GUI) saveTemplates() error {
     if err != nil {
         return err
     }
+    if g.templatesLocked {
+        return fmt.Errorf("Template store is locked")
+    }
     templatePath := filepath.Join(configPath, configDir, templateFile)
     data, err := json.MarshalIndent(g.templates, "", "  ")
     if err != nil {
         return err
     }
-    return os.WriteFile(templatePath, data, 0644)
+    if g.encryptTemplates {
+        if g.masterPassphrase == nil {
+            return fmt.Errorf("No master passphrase set")
+        }
+        sealed, err := sealTemplates(data, g.masterPassphrase)
+        zero(data)
+        if err != nil {
+            return err
+        }
+        data = sealed
+    }
+    return writeFileAtomic(templatePath, data, 0600)
 }
 
 func (g *GUI) saveTemplate() {
@@ -470,10 +807,14 @@ func (g *GUI) saveTemplate() {
     headers := strings.TrimSpace(g.templateEditor.Text)
     body := strings.TrimSpace(g.messageEnt.Text)
     newTemplate := Template{
-        Name:        g.templateName.Text,
-        Description: g.templateDesc.Text,
-        Headers:     headers,
-        Body:        body,
+        Name:            g.templateName.Text,
+        Description:     g.templateDesc.Text,
+        Headers:         headers,
+        Body:            body,
+        MessageIDDomain: strings.TrimSpace(g.templateMessageIDDomain.Text),
+    }
+    if validChoice(messageIDStrategies, g.templateMessageIDSelect.Selected) {
+        newTemplate.MessageID = g.templateMessageIDSelect.Selected
     }
     found := false
     for i, t := range g.templates {
@@ -500,6 +841,12 @@ func (g *GUI) selectTemplate(id widget.ListItemID) {
     g.templateDesc.SetText(template.Description)
     g.templateEditor.SetText(template.Headers)
     g.messageEnt.SetText(template.Body)
+    if validChoice(messageIDStrategies, template.MessageID) {
+        g.templateMessageIDSelect.SetSelected(template.MessageID)
+    } else {
+        g.templateMessageIDSelect.SetSelected(messageIDProfileDefault)
+    }
+    g.templateMessageIDDomain.SetText(template.MessageIDDomain)
 }
 
 func (g *GUI) deleteTemplate() {
@@ -517,6 +864,8 @@ func (g *GUI) deleteTemplate() {
     g.templateDesc.SetText("")
     g.templateEditor.SetText("")
     g.messageEnt.SetText("")
+    g.templateMessageIDSelect.SetSelected(messageIDProfileDefault)
+    g.templateMessageIDDomain.SetText("")
     g.selectedTemplate = -1
 }
 
@@ -536,6 +885,9 @@ func (g *GUI) buildTemplateEditor() *fyne.Container {
     g.templateDesc.SetPlaceHolder("Description")
     g.templateEditor = widget.NewMultiLineEntry()
     g.templateEditor.SetPlaceHolder("Email headers")
+    g.templateMessageIDSelect = newTemplateMessageIDSelect()
+    g.templateMessageIDDomain = widget.NewEntry()
+    g.templateMessageIDDomain.SetPlaceHolder("Profile's domain")
 
     copyButton := widget.NewButton("Copy", func() {
         if g.selectedTemplate < 0 || g.selectedTemplate >= len(g.templates) {
@@ -544,7 +896,7 @@ func (g *GUI) buildTemplateEditor() *fyne.Container {
         }
         selected := g.templates[g.selectedTemplate]
         full := selected.Headers + "\n" + selected.Body
-        if err := clipboard.WriteAll(full); err != nil {
+        if err := g.copyToClipboard(full); err != nil {
             dialog.ShowError(fmt.Errorf("Failed to copy to clipboard: %v", err), g.window)
         }
     })
@@ -555,11 +907,15 @@ func (g *GUI) buildTemplateEditor() *fyne.Container {
             g.templateDesc.SetText("")
             g.templateEditor.SetText("")
             g.messageEnt.SetText("")
+            g.templateMessageIDSelect.SetSelected(messageIDProfileDefault)
+            g.templateMessageIDDomain.SetText("")
             g.selectedTemplate = -1
         }),
         widget.NewButton("Save", g.saveTemplate),
         widget.NewButton("Delete", g.deleteTemplate),
         copyButton,
+        widget.NewButton("Export", g.exportTemplates),
+        g.encryptTemplatesCheck,
     )
 
     return container.NewBorder(
@@ -570,6 +926,8 @@ func (g *GUI) buildTemplateEditor() *fyne.Container {
             widget.NewForm(
                 widget.NewFormItem("Name", g.templateName),
                 widget.NewFormItem("Description", g.templateDesc),
+                widget.NewFormItem("Message-ID", g.templateMessageIDSelect),
+                widget.NewFormItem("Message-ID domain", g.templateMessageIDDomain),
             ),
             controls,
         ),
@@ -615,20 +973,31 @@ func (g *GUI) buildComposeTab() *fyne.Container {
         }
     })
 
+    replyButton := widget.NewButton("Reply", g.showReplyDialog)
+
     sendButton := widget.NewButton("Send Email", g.sendEmail)
+    g.usenetCheck = widget.NewCheck("Usenet", func(checked bool) {
+        if checked {
+            sendButton.SetText("Post Article")
+        } else {
+            sendButton.SetText("Send Email")
+        }
+    })
 
     buttonContainer := container.NewHBox(
         layout.NewSpacer(),
         pasteButton,
         clearButton,
         clearClipboardButton,
+        replyButton,
+        g.usenetCheck,
         sendButton,
         layout.NewSpacer(),
     )
 
     return container.NewBorder(
         nil,
-        container.NewVBox(buttonContainer, scrollStatus),
+        container.NewVBox(g.buildAttachmentArea(), buttonContainer, scrollStatus),
         nil, nil,
         container.NewScroll(g.messageEnt),
     )
@@ -639,7 +1008,7 @@ func (g *GUI) buildConfigTab() *fyne.Container {
     g.themeEntry.SetPlaceHolder("Enter 'light' or 'dark'")
     g.themeEntry.SetText("dark")
     
-    g.omitHeadersCheck = widget.NewCheck("Omit auto Message-ID and Date", func(checked bool) {})
+    g.omitHeadersCheck = widget.NewCheck("Omit auto Message-ID", func(checked bool) {})
     g.omitHeadersCheck.SetChecked(false)
 
     loadButton := widget.NewButton("Load Config", g.loadConfig)
@@ -651,6 +1020,8 @@ func (g *GUI) buildConfigTab() *fyne.Container {
         }
         g.saveConfig()
     })
+    encryptAllButton := widget.NewButton("Encrypt All Profiles", g.encryptAllProfiles)
+    importKeysButton := widget.NewButton("Import PGP Keys", g.importPGPKeyring)
 
     return container.NewVBox(
         widget.NewForm(
@@ -661,12 +1032,43 @@ func (g *GUI) buildConfigTab() *fyne.Container {
             widget.NewFormItem("SOCKS5 Port", g.socksPortEnt),
             widget.NewFormItem("Config File", g.configFile),
             widget.NewFormItem("esub Key", g.esubKeyEntry),
+            widget.NewFormItem("esub Scheme", g.esubSchemeSelect),
             widget.NewFormItem("Hashcash Bits", g.hashcashBitsEntry),
             widget.NewFormItem("Hashcash Receiver", g.hashcashReceiverEntry),
             widget.NewFormItem("Theme (light/dark)", g.themeEntry),
-            widget.NewFormItem("Omit auto headers", g.omitHeadersCheck), // Neue Zeile
+            widget.NewFormItem("Omit auto Message-ID", g.omitHeadersCheck), // Neue Zeile
+            widget.NewFormItem("Clipboard clear (s)", g.clipboardClearEntry),
+            widget.NewFormItem("PGP", g.pgpModeSelect),
+            widget.NewFormItem("PGP Format", g.pgpFormatSelect),
+            widget.NewFormItem("Mail2news gateways", g.mail2newsEntry),
+            widget.NewFormItem("", g.mail2newsAllCheck),
+            widget.NewFormItem("Post Usenet via", g.usenetRouteSelect),
+            widget.NewFormItem("NNTP Host", g.nntpHostEnt),
+            widget.NewFormItem("NNTP Port", g.nntpPortEnt),
+            widget.NewFormItem("NNTP Security", g.nntpSecuritySelect),
+            widget.NewFormItem("NNTP Username", g.nntpUsernameEnt),
+            widget.NewFormItem("NNTP Password", g.nntpPasswordEnt),
+            widget.NewFormItem("Reader Group", g.readerGroupEnt),
+            widget.NewFormItem("POP3 Host", g.pop3HostEnt),
+            widget.NewFormItem("POP3 Port", g.pop3PortEnt),
+            widget.NewFormItem("POP3 Security", g.pop3SecuritySelect),
+            widget.NewFormItem("POP3 Username", g.pop3UsernameEnt),
+            widget.NewFormItem("POP3 Password", g.pop3PasswordEnt),
+            widget.NewFormItem("", g.pop3DeleteCheck),
+            widget.NewFormItem("IMAP Host", g.imapHostEnt),
+            widget.NewFormItem("IMAP Port", g.imapPortEnt),
+            widget.NewFormItem("IMAP Security", g.imapSecuritySelect),
+            widget.NewFormItem("IMAP Username", g.imapUsernameEnt),
+            widget.NewFormItem("IMAP Password", g.imapPasswordEnt),
+            widget.NewFormItem("Identifying headers", g.headerLintSelect),
+            widget.NewFormItem("Header order", g.headerOrderSelect),
+            widget.NewFormItem("Date header", g.datePolicySelect),
+            widget.NewFormItem("Date jitter (min)", g.dateJitterEntry),
+            widget.NewFormItem("Message-ID", g.messageIDSelect),
+            widget.NewFormItem("Message-ID domain", g.messageIDDomainEntry),
+            widget.NewFormItem("Encrypt profile", g.encryptConfigCheck),
         ),
-        container.NewHBox(loadButton, saveButton),
+        container.NewHBox(loadButton, saveButton, encryptAllButton, importKeysButton),
     )
 }
 
@@ -674,12 +1076,18 @@ func (g *GUI) buildUI() {
     if err := g.loadTemplates(); err != nil {
         dialog.ShowError(err, g.window)
     }
-    tabs := container.NewAppTabs(
+    g.tabs = container.NewAppTabs(
         container.NewTabItem("Compose", g.buildComposeTab()),
+        container.NewTabItem("Inbox", g.buildInboxTab()),
         container.NewTabItem("Templates", g.buildTemplateEditor()),
+        container.NewTabItem("Remailers", g.buildRemailerTab()),
+        container.NewTabItem("Nym Accounts", g.buildNymTab()),
+        container.NewTabItem("Reply Blocks", g.buildReplyBlockTab()),
+        container.NewTabItem("Local Inbox", g.buildLocalInboxTab()),
         container.NewTabItem("Configuration", g.buildConfigTab()),
     )
-    mainContainer := container.NewBorder(nil, nil, nil, nil, tabs)
+    g.refreshRemailerDirectory()
+    mainContainer := container.NewBorder(nil, nil, nil, nil, g.tabs)
     g.window.SetContent(mainContainer)
 }
 
@@ -688,9 +1096,6 @@ func NewGUI() *GUI {
     myApp.Settings().SetTheme(theme.DarkTheme())
     window := myApp.NewWindow("Mini Mailer")
     window.Resize(fyne.NewSize(800, 600))
-    window.SetOnClosed(func() {
-        window.Clipboard().SetContent("")
-    })
 
     gui := &GUI{
         app:             myApp,
@@ -710,10 +1115,17 @@ func NewGUI() *GUI {
         statusLabel:     widget.NewMultiLineEntry(),
         encodeMIMESubjectEntry: widget.NewEntry(),
         esubKeyEntry:   widget.NewEntry(),
+        esubSchemeSelect: widget.NewSelect(esubSchemes, nil),
         hashcashBitsEntry:   widget.NewEntry(),
         hashcashReceiverEntry: widget.NewEntry(),
         themeEntry:      widget.NewEntry(),
+        clipboardClearEntry: widget.NewEntry(),
     }
+    window.SetOnClosed(func() {
+        window.Clipboard().SetContent("")
+        esubKeys.wipe()
+        wipeBytes(gui.masterPassphrase)
+    })
     gui.statusLabel.Wrapping = fyne.TextWrapWord
     gui.statusLabel.Disable()
     gui.statusLabel.SetText("Ready to send")
@@ -729,11 +1141,63 @@ func (g *GUI) ShowAndRun() {
     g.passwordEnt = widget.NewEntry()
     g.socksPortEnt = widget.NewEntry()
     g.esubKeyEntry = widget.NewEntry()
+    g.esubSchemeSelect = widget.NewSelect(esubSchemes, nil)
+    g.esubSchemeSelect.SetSelected(esubSchemeMMG)
     g.hashcashBitsEntry = widget.NewEntry()
     g.hashcashReceiverEntry = widget.NewEntry()
     g.configFile = widget.NewEntry()
     g.encodeMIMESubjectEntry = widget.NewEntry()
     g.omitHeadersCheck = widget.NewCheck("", nil)
+    g.encryptConfigCheck = widget.NewCheck("Encrypt with master passphrase", nil)
+    g.clipboardClearEntry = widget.NewEntry()
+    g.clipboardClearEntry.SetPlaceHolder("30, 0 to keep")
+    g.pgpModeSelect = widget.NewSelect(pgpModes, nil)
+    g.pgpModeSelect.SetSelected(pgpModeOff)
+    g.pgpFormatSelect = widget.NewSelect(pgpFormats, nil)
+    g.pgpFormatSelect.SetSelected(pgpFormatInline)
+    g.encryptTemplatesCheck = widget.NewCheck("Encrypt", nil)
+    g.mail2newsEntry = widget.NewMultiLineEntry()
+    g.mail2newsEntry.SetPlaceHolder("mail2news@gateway.example, one per line")
+    g.mail2newsAllCheck = widget.NewCheck("Post through every gateway", nil)
+    g.usenetRouteSelect = widget.NewSelect(usenetRoutes, nil)
+    g.usenetRouteSelect.SetSelected(usenetRouteMail2News)
+    g.nntpHostEnt = widget.NewEntry()
+    g.nntpPortEnt = widget.NewEntry()
+    g.nntpPortEnt.SetPlaceHolder("119, 563 for nntps")
+    g.nntpSecuritySelect = widget.NewSelect(nntpSecurities, nil)
+    g.nntpSecuritySelect.SetSelected(nntpSecurityStartTLS)
+    g.nntpUsernameEnt = widget.NewEntry()
+    g.nntpPasswordEnt = widget.NewEntry()
+    g.readerGroupEnt = widget.NewEntry()
+    g.readerGroupEnt.SetPlaceHolder(defaultReaderGroup)
+    g.pop3HostEnt = widget.NewEntry()
+    g.pop3PortEnt = widget.NewEntry()
+    g.pop3PortEnt.SetPlaceHolder("110, 995 for pop3s")
+    g.pop3SecuritySelect = widget.NewSelect(pop3Securities, nil)
+    g.pop3SecuritySelect.SetSelected(pop3SecuritySTLS)
+    g.pop3UsernameEnt = widget.NewEntry()
+    g.pop3PasswordEnt = widget.NewEntry()
+    g.pop3DeleteCheck = widget.NewCheck("Delete from server after fetch", nil)
+    g.imapHostEnt = widget.NewEntry()
+    g.imapPortEnt = widget.NewEntry()
+    g.imapPortEnt.SetPlaceHolder("143, 993 for imaps")
+    g.imapSecuritySelect = widget.NewSelect(imapSecurities, nil)
+    g.imapSecuritySelect.SetSelected(imapSecurityStartTLS)
+    g.imapUsernameEnt = widget.NewEntry()
+    g.imapPasswordEnt = widget.NewEntry()
+    g.headerLintSelect = widget.NewSelect(headerLintPolicies, nil)
+    g.headerLintSelect.SetSelected(headerLintWarn)
+    g.headerOrderSelect = widget.NewSelect(headerOrders, nil)
+    g.headerOrderSelect.SetSelected(headerOrderKeep)
+    g.datePolicySelect = widget.NewSelect(datePolicies, nil)
+    g.datePolicySelect.SetSelected(datePolicyExact)
+    g.dateJitterEntry = widget.NewEntry()
+    g.dateJitterEntry.SetPlaceHolder("30, used by jitter")
+    g.messageIDSelect = widget.NewSelect(messageIDStrategies, nil)
+    g.messageIDSelect.SetSelected(messageIDClassic)
+    g.messageIDDomainEntry = widget.NewEntry()
+    g.messageIDDomainEntry.SetPlaceHolder("example.org, used by uuid and random")
+    g.encryptTemplatesCheck.OnChanged = g.setTemplateEncryption
 
     miscMenu := g.createMiscMenu()
     mainMenu := fyne.NewMainMenu(miscMenu)
@@ -802,41 +1266,122 @@ func isValidEmail(email string) bool {
     return strings.Contains(email, "@") && strings.Contains(email, ".")
 }
 
-func (g *GUI) sendEmail() {
-    rawContent := normalizeLineEndings(g.messageEnt.Text)
+// prepareMessage turns the text typed in the compose box into a CRLF
+// message with non-ASCII headers encoded and the automatic headers added,
+// and returns its envelope addresses.
+func (g *GUI) prepareMessage(text string) (from, to, rawContent string, err error) {
+    rawContent = encodeHeaders(normalizeLineEndings(text))
     headers := parseHeaders(rawContent)
-    from := extractEmailFromHeaders(headers, "from")
-    to := extractEmailFromHeaders(headers, "to")
+    from = extractEmailFromHeaders(headers, "from")
+    to = extractEmailFromHeaders(headers, "to")
     if !isValidEmail(from) || !isValidEmail(to) {
-        fyne.Do(func() {
-            dialog.ShowError(fmt.Errorf("Invalid 'From' or 'To' address"), g.window)
-        })
-        return
+        return "", "", "", fmt.Errorf("Invalid 'From' or 'To' address")
+    }
+    rawContent, err = g.addAutoHeaders(rawContent)
+    if err != nil {
+        return "", "", "", err
     }
+    return from, to, rawContent, nil
+}
 
+// addAutoHeaders adds Message-ID and Date to a CRLF message that lacks
+// them. The Message-ID is left out when the profile turns it off; the
+// Date follows the profile's date policy, which may omit it.
+func (g *GUI) addAutoHeaders(rawContent string) (string, error) {
+    headers := parseHeaders(rawContent)
     var messageIDHeader, dateHeader string
-    if !g.omitHeadersCheck.Checked {
-        if _, exists := headers["message-id"]; !exists {
-            messageIDHeader = fmt.Sprintf("Message-ID: %s\r\n", generateMessageID())
+    if _, exists := headers["message-id"]; !exists && !g.omitHeadersCheck.Checked {
+        id, err := g.autoMessageID()
+        if err != nil {
+            return "", err
         }
-        if _, exists := headers["date"]; !exists {
-            dateHeader = fmt.Sprintf("Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+        messageIDHeader = fmt.Sprintf("Message-ID: %s\r\n", id)
+    }
+    if _, exists := headers["date"]; !exists {
+        minutes, err := parseDateJitter(g.dateJitterEntry.Text)
+        if err != nil {
+            return "", err
+        }
+        date, err := policyDate(time.Now(), g.datePolicySelect.Selected, minutes)
+        if err != nil {
+            return "", err
+        }
+        if date != "" {
+            dateHeader = fmt.Sprintf("Date: %s\r\n", date)
         }
     }
 
     parts := strings.SplitN(rawContent, "\r\n\r\n", 2)
     if len(parts) == 2 {
-        rawContent = parts[0] + "\r\n" + messageIDHeader + dateHeader + "\r\n" + parts[1]
-    } else {
-        rawContent = rawContent + "\r\n" + messageIDHeader + dateHeader + "\r\n"
+        return parts[0] + "\r\n" + messageIDHeader + dateHeader + "\r\n" + parts[1], nil
     }
+    return rawContent + "\r\n" + messageIDHeader + dateHeader + "\r\n", nil
+}
 
+func (g *GUI) sendEmail() {
+    if g.usenetCheck.Checked {
+        g.postUsenet()
+        return
+    }
+    text, err := g.resolveReplyBlocks(g.messageEnt.Text)
+    if err != nil {
+        fyne.Do(func() {
+            dialog.ShowError(err, g.window)
+        })
+        return
+    }
+    from, to, rawContent, err := g.prepareMessage(text)
+    if err != nil {
+        fyne.Do(func() {
+            dialog.ShowError(err, g.window)
+        })
+        return
+    }
+
+    if len(g.attachments) > 0 {
+        mode := g.pgpModeSelect.Selected
+        if mode != "" && mode != pgpModeOff && g.pgpFormatSelect.Selected != pgpFormatMIME {
+            dialog.ShowError(fmt.Errorf("Attachments need the PGP/MIME format"), g.window)
+            return
+        }
+        if err := g.uncleanAttachments(); err != nil {
+            dialog.ShowError(err, g.window)
+            return
+        }
+        rawContent, err = buildMixed(rawContent, g.attachments)
+        if err != nil {
+            dialog.ShowError(fmt.Errorf("Failed to attach files: %v", err), g.window)
+            return
+        }
+    }
+
+    g.applyPGP(rawContent, from, g.pgpModeSelect.Selected, g.pgpFormatSelect.Selected, func(message string) {
+        g.deliver(from, to, message, nil)
+    })
+}
+
+// deliver checks the finished message for identifying headers and hands
+// it to the SMTP server through the SOCKS5 proxy. onSent, if not nil,
+// runs on the UI thread once the server accepted the message and the
+// session ended with a clean QUIT.
+func (g *GUI) deliver(from, to, rawContent string, onSent func()) {
+    g.checkHeaders(rawContent, func() {
+        g.sendSMTP(from, to, rawContent, onSent)
+    })
+}
+
+// sendSMTP runs the SMTP session for deliver, after the header lint.
+func (g *GUI) sendSMTP(from, to, rawContent string, onSent func()) {
     fyne.Do(func() {
         g.statusLabel.SetText("Starting SMTP session...")
     })
 
+    password := secretBytes(g.passwordEnt.Text)
+
     go func() {
-                updateStatus := func(text string) {
+        defer wipeBytes(password)
+
+        updateStatus := func(text string) {
             fyne.Do(func() {
                 g.statusLabel.SetText(text)
             })
@@ -848,6 +1393,18 @@ func (g *GUI) sendEmail() {
             })
         }
 
+        for _, f := range [][2]string{{"SMTP Host", g.hostEnt.Text}, {"SMTP Port", g.portEnt.Text}, {"Username", g.usernameEnt.Text}, {"SOCKS5 Port", g.socksPortEnt.Text}} {
+            if err := checkLine(f[0], f[1]); err != nil {
+                updateStatus("Settings Error: " + err.Error())
+                showError(err)
+                return
+            }
+        }
+        if err := checkSecret("Password", password); err != nil {
+            updateStatus("Settings Error: " + err.Error())
+            showError(err)
+            return
+        }
         updateStatus("Connecting to SOCKS proxy...")
         dialer, err := proxy.SOCKS5("tcp", "127.0.0.1:"+g.socksPortEnt.Text, nil, proxy.Direct)
         if err != nil {
@@ -872,7 +1429,7 @@ func (g *GUI) sendEmail() {
             showError(fmt.Errorf("SMTP init failed: %v", err))
             return
         }
-        defer client.Quit()
+        defer client.Close()
 
         updateStatus("Starting TLS...")
         if err := client.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
@@ -881,16 +1438,49 @@ func (g *GUI) sendEmail() {
             return
         }
 
-        if g.usernameEnt.Text != "" && g.passwordEnt.Text != "" {
+        if g.usernameEnt.Text != "" && len(password) > 0 {
             updateStatus("Authenticating...")
-            auth := smtp.PlainAuth("", g.usernameEnt.Text, g.passwordEnt.Text, g.hostEnt.Text)
-            if err := client.Auth(auth); err != nil {
+            auth := newPlainAuth(g.usernameEnt.Text, password, g.hostEnt.Text)
+            err := client.Auth(auth)
+            auth.wipe()
+            if err != nil {
                 updateStatus("Auth Error: " + err.Error())
                 showError(fmt.Errorf("Auth failed: %v", err))
                 return
             }
         }
 
+        eightBit, _ := client.Extension("8BITMIME")
+        if smtpUTF8, _ := client.Extension("SMTPUTF8"); !smtpUTF8 && (!isASCII(from) || !isASCII(to)) {
+            updateStatus("SMTPUTF8 Error: server does not accept non-ASCII addresses")
+            showError(fmt.Errorf("Server does not support SMTPUTF8, needed for non-ASCII addresses"))
+            return
+        }
+        if !eightBit && needsEightBit(rawContent) {
+            updateStatus("8BITMIME Error: server does not accept 8bit bodies")
+            showError(fmt.Errorf("Server does not support 8BITMIME, needed for this 8bit message"))
+            return
+        }
+        rawContent = prepareBody(rawContent, eightBit)
+        rawContent, err = g.finalizeHeaders(rawContent)
+        if err != nil {
+            updateStatus("Header Error: " + err.Error())
+            showError(fmt.Errorf("Refusing to send: %v", err))
+            return
+        }
+
+        if ok, param := client.Extension("SIZE"); ok {
+            if limit, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64); err == nil && limit > 0 {
+                g.smtpSizeLimit.Store(limit)
+                fyne.Do(g.refreshAttachments)
+                if int64(len(rawContent)) > limit {
+                    updateStatus("SIZE Error: message exceeds the server limit")
+                    showError(fmt.Errorf("Message is %s, the server accepts at most %s", formatSize(int64(len(rawContent))), formatSize(limit)))
+                    return
+                }
+            }
+        }
+
         updateStatus("Sending MAIL FROM...")
         if err := client.Mail(from); err != nil {
             updateStatus("MAIL FROM Error: " + err.Error())
@@ -912,15 +1502,27 @@ func (g *GUI) sendEmail() {
             showError(fmt.Errorf("DATA failed: %v", err))
             return
         }
-        defer w.Close()
-
         if _, err := w.Write([]byte(rawContent)); err != nil {
             updateStatus("Write Error: " + err.Error())
             showError(fmt.Errorf("Message write failed: %v", err))
             return
         }
+        if err := w.Close(); err != nil {
+            updateStatus("DATA Error: " + err.Error())
+            showError(fmt.Errorf("Message not accepted: %v", err))
+            return
+        }
+
+        if err := client.Quit(); err != nil {
+            updateStatus("QUIT Error: " + err.Error())
+            showError(fmt.Errorf("QUIT failed: %v", err))
+            return
+        }
 
         updateStatus("Email sent successfully")
+        if onSent != nil {
+            fyne.Do(onSent)
+        }
     }()
 }
 
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	defaultReaderGroup = "alt.anonymous.messages"
+	readerStateFile    = "reader.json"
+	readerStateFormat  = "mmg-reader-v1"
+	newsInboxDir       = "news"
+)
+
+// readerWindow caps how many articles are scanned on the first visit to
+// a group, so a fresh profile does not pull years of overview data.
+const readerWindow = 2000
+
+// newsOverview is one line of OVER output.
+type newsOverview struct {
+	Number    int
+	Subject   string
+	From      string
+	Date      string
+	MessageID string
+}
+
+// newsArticle is a fetched article that matched one of the esub keys.
+type newsArticle struct {
+	Group   string
+	Number  int
+	Subject string
+	Raw     string
+}
+
+// newsSource is the part of an NNTP server the reader needs. nntpClient
+// implements it; anything else that does, such as a local stand-in, can
+// be scanned the same way.
+type newsSource interface {
+	group(name string) (low, high int, err error)
+	overview(low, high int) ([]newsOverview, error)
+	article(number int) (string, error)
+}
+
+func (c *nntpClient) group(name string) (low, high int, err error) {
+	msg, err := c.cmd(211, "GROUP %s", name)
+	if err != nil {
+		return 0, 0, err
+	}
+	var count int
+	if _, err := fmt.Sscanf(msg, "%d %d %d", &count, &low, &high); err != nil {
+		return 0, 0, fmt.Errorf("malformed GROUP response %q", msg)
+	}
+	return low, high, nil
+}
+
+func (c *nntpClient) overview(low, high int) ([]newsOverview, error) {
+	_, err := c.cmd(224, "OVER %d-%d", low, high)
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) && tpErr.Code == 500 {
+		// Servers older than RFC 3977 only know XOVER.
+		_, err = c.cmd(224, "XOVER %d-%d", low, high)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	var over []newsOverview
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		over = append(over, newsOverview{
+			Number:    n,
+			Subject:   fields[1],
+			From:      fields[2],
+			Date:      fields[3],
+			MessageID: fields[4],
+		})
+	}
+	return over, nil
+}
+
+// article fetches article number from the current group as a CRLF
+// message.
+func (c *nntpClient) article(number int) (string, error) {
+	if _, err := c.cmd(220, "ARTICLE %d", number); err != nil {
+		return "", err
+	}
+	data, err := c.text.ReadDotBytes()
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(string(data), "\n", "\r\n"), nil
+}
+
+// scanGroup reads the overview of every article in group after last,
+// tests each Subject against keys and fetches only the matching articles.
+// It returns the matches and the number to pass as last next time.
+// Articles that expired between OVER and ARTICLE are skipped.
+func scanGroup(src newsSource, group string, last int, keys []esub) ([]newsArticle, int, error) {
+	low, high, err := src.group(group)
+	if err != nil {
+		return nil, last, err
+	}
+	if high < last {
+		// The server renumbered the group; start over.
+		last = 0
+	}
+	start := last + 1
+	if last == 0 && high-readerWindow+1 > start {
+		start = high - readerWindow + 1
+	}
+	if start < low {
+		start = low
+	}
+	if start > high {
+		return nil, high, nil
+	}
+
+	over, err := src.overview(start, high)
+	if err != nil {
+		return nil, last, err
+	}
+	subjects := make([]string, len(over))
+	for i, o := range over {
+		subjects[i] = strings.TrimSpace(o.Subject)
+	}
+	hit := make([]bool, len(over))
+	for i := range keys {
+		for j, ok := range keys[i].esubtestBatch(subjects) {
+			hit[j] = hit[j] || ok
+		}
+	}
+
+	var matches []newsArticle
+	for i, o := range over {
+		if !hit[i] {
+			continue
+		}
+		raw, err := src.article(o.Number)
+		var tpErr *textproto.Error
+		if errors.As(err, &tpErr) && (tpErr.Code == 423 || tpErr.Code == 430) {
+			continue
+		}
+		if err != nil {
+			return matches, last, err
+		}
+		matches = append(matches, newsArticle{Group: group, Number: o.Number, Subject: subjects[i], Raw: raw})
+	}
+	return matches, high, nil
+}
+
+// readerKeys collects the esub keys to scan for: the profile's key with
+// its scheme, and every nym account's key under each scheme, since the
+// scheme a nym server uses is not recorded. The keys are held in locked
+// buffers; release them with wipeKeys.
+func (g *GUI) readerKeys() []esub {
+	var keys []esub
+	if k := g.esubKeyEntry.Text; k != "" {
+		keys = append(keys, esub{key: secretBytes(k), scheme: g.esubSchemeSelect.Selected})
+	}
+	for _, n := range g.nyms {
+		if n.EsubKey == "" {
+			continue
+		}
+		for _, scheme := range esubSchemes {
+			keys = append(keys, esub{key: secretBytes(n.EsubKey), scheme: scheme})
+		}
+	}
+	return keys
+}
+
+// wipeKeys zeroes and unlocks the key buffers of keys from readerKeys.
+func wipeKeys(keys []esub) {
+	for i := range keys {
+		wipeBytes(keys[i].key)
+	}
+}
+
+func newsInboxPath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "inbox", newsInboxDir), nil
+}
+
+// saveNewsArticles stores each article as group-number.eml in the local
+// news inbox.
+func saveNewsArticles(articles []newsArticle) error {
+	dir, err := newsInboxPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for _, a := range articles {
+		name := fmt.Sprintf("%s-%d.eml", a.Group, a.Number)
+		if err := writeFileAtomic(filepath.Join(dir, name), []byte(a.Raw), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listNewsInbox returns the file names in the local news inbox, newest
+// first.
+func listNewsInbox() ([]string, error) {
+	dir, err := newsInboxPath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".eml") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// fetchNews connects to the profile's news server, scans the reader
+// group and stores the matches in the news inbox.
+func (g *GUI) fetchNews(onDone func()) {
+	group := strings.TrimSpace(g.readerGroupEnt.Text)
+	if group == "" {
+		group = defaultReaderGroup
+	}
+	host := strings.TrimSpace(g.nntpHostEnt.Text)
+	if host == "" {
+		dialog.ShowError(fmt.Errorf("No NNTP server configured"), g.window)
+		return
+	}
+	keys := g.readerKeys()
+	if len(keys) == 0 {
+		dialog.ShowError(fmt.Errorf("No esub key set in the profile or nym accounts"), g.window)
+		return
+	}
+	state := make(map[string]int)
+	locked, err := g.readStore(readerStateFile, readerStateFormat, &state)
+	if err != nil {
+		wipeKeys(keys)
+		dialog.ShowError(fmt.Errorf("Failed to load reader state: %v", err), g.window)
+		return
+	}
+	if locked {
+		wipeKeys(keys)
+		g.showUnlockDialog(storeCheck(readerStateFile, readerStateFormat), func() { g.fetchNews(onDone) })
+		return
+	}
+
+	port := strings.TrimSpace(g.nntpPortEnt.Text)
+	security := g.nntpSecuritySelect.Selected
+	username := g.nntpUsernameEnt.Text
+	password := secretBytes(g.nntpPasswordEnt.Text)
+
+	go func() {
+		defer wipeBytes(password)
+		defer wipeKeys(keys)
+
+		updateStatus := func(text string) {
+			fyne.Do(func() {
+				g.statusLabel.SetText(text)
+			})
+		}
+
+		showError := func(err error) {
+			fyne.Do(func() {
+				dialog.ShowError(err, g.window)
+			})
+		}
+
+		updateStatus("Connecting to SOCKS proxy...")
+		dialer, err := proxy.SOCKS5("tcp", "127.0.0.1:"+g.socksPortEnt.Text, nil, proxy.Direct)
+		if err != nil {
+			updateStatus("SOCKS Error: " + err.Error())
+			showError(fmt.Errorf("SOCKS5 error: %v", err))
+			return
+		}
+
+		updateStatus("Connecting to NNTP server...")
+		client, err := dialNNTP(dialer, host, port, security)
+		if err != nil {
+			updateStatus("Connection Error: " + err.Error())
+			showError(fmt.Errorf("Connection failed: %v", err))
+			return
+		}
+		defer client.quit()
+
+		if username != "" && len(password) > 0 {
+			updateStatus("Authenticating...")
+			if err := client.auth(username, password); err != nil {
+				updateStatus("Auth Error: " + err.Error())
+				showError(fmt.Errorf("Auth failed: %v", err))
+				return
+			}
+		}
+
+		updateStatus("Scanning " + group + "...")
+		matches, last, err := scanGroup(client, group, state[group], keys)
+		if serr := saveNewsArticles(matches); serr != nil {
+			showError(fmt.Errorf("Failed to save articles: %v", serr))
+			return
+		}
+		if err != nil {
+			updateStatus("Scan Error: " + err.Error())
+			showError(fmt.Errorf("Scan failed: %v", err))
+			return
+		}
+
+		fyne.Do(func() {
+			state[group] = last
+			if err := g.writeStore(readerStateFile, readerStateFormat, state); err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to save reader state: %v", err), g.window)
+			}
+			g.statusLabel.SetText(fmt.Sprintf("%d new message(s) from %s", len(matches), group))
+			onDone()
+		})
+	}()
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/proxy"
+)
+
+// fakeNews is a newsSource holding one group in memory.
+type fakeNews struct {
+	low, high int
+	subjects  map[int]string
+	missing   map[int]int // article number -> NNTP error code
+	overRange [2]int      // the range of the last overview call
+	fetched   []int
+}
+
+func newFakeNews(low, high int) *fakeNews {
+	return &fakeNews{low: low, high: high, subjects: make(map[int]string), missing: make(map[int]int)}
+}
+
+func (f *fakeNews) group(name string) (int, int, error) {
+	return f.low, f.high, nil
+}
+
+func (f *fakeNews) overview(low, high int) ([]newsOverview, error) {
+	f.overRange = [2]int{low, high}
+	var over []newsOverview
+	for n := low; n <= high; n++ {
+		subject, ok := f.subjects[n]
+		if !ok {
+			subject = "unrelated"
+		}
+		over = append(over, newsOverview{Number: n, Subject: subject})
+	}
+	return over, nil
+}
+
+func (f *fakeNews) article(number int) (string, error) {
+	f.fetched = append(f.fetched, number)
+	if code, ok := f.missing[number]; ok {
+		return "", &textproto.Error{Code: code, Msg: "no such article"}
+	}
+	return fmt.Sprintf("Subject: %s\r\n\r\narticle %d\r\n", f.subjects[number], number), nil
+}
+
+// post adds an article with a subject matching key as number n.
+func (f *fakeNews) post(t *testing.T, key esub, n int) {
+	t.Helper()
+	subject, err := key.esubgen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.subjects[n] = subject
+	if n > f.high {
+		f.high = n
+	}
+}
+
+func testReaderKey() esub {
+	return esub{key: []byte("reader test key"), scheme: esubSchemeClassic}
+}
+
+func articleNumbers(articles []newsArticle) []int {
+	var numbers []int
+	for _, a := range articles {
+		numbers = append(numbers, a.Number)
+	}
+	return numbers
+}
+
+func TestScanGroup(t *testing.T) {
+	key := testReaderKey()
+	tests := []struct {
+		name      string
+		low, high int
+		last      int
+		hits      []int
+		missing   map[int]int
+		wantRange [2]int
+		wantHits  []int
+		wantLast  int
+	}{
+		{
+			name: "first visit scans the window",
+			low:  1, high: 5000,
+			hits:      []int{2500, 4000, 5000},
+			wantRange: [2]int{5000 - readerWindow + 1, 5000},
+			wantHits:  []int{4000, 5000},
+			wantLast:  5000,
+		},
+		{
+			name: "first visit to a small group starts at low",
+			low:  10, high: 20,
+			hits:      []int{10, 15},
+			wantRange: [2]int{10, 20},
+			wantHits:  []int{10, 15},
+			wantLast:  20,
+		},
+		{
+			name: "continues after last",
+			low:  1, high: 120,
+			last:      100,
+			hits:      []int{90, 110},
+			wantRange: [2]int{101, 120},
+			wantHits:  []int{110},
+			wantLast:  120,
+		},
+		{
+			name: "renumbered group starts over",
+			low:  1, high: 50,
+			last:      900,
+			hits:      []int{3, 49},
+			wantRange: [2]int{1, 50},
+			wantHits:  []int{3, 49},
+			wantLast:  50,
+		},
+		{
+			name: "expired articles are skipped",
+			low:  1, high: 30,
+			hits:      []int{5, 10, 20},
+			missing:   map[int]int{5: 423, 20: 430},
+			wantRange: [2]int{1, 30},
+			wantHits:  []int{10},
+			wantLast:  30,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := newFakeNews(tt.low, tt.high)
+			for _, n := range tt.hits {
+				src.post(t, key, n)
+			}
+			for n, code := range tt.missing {
+				src.missing[n] = code
+			}
+			matches, last, err := scanGroup(src, "alt.test", tt.last, []esub{key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src.overRange != tt.wantRange {
+				t.Errorf("overview range = %v, want %v", src.overRange, tt.wantRange)
+			}
+			if got := articleNumbers(matches); fmt.Sprint(got) != fmt.Sprint(tt.wantHits) {
+				t.Errorf("matches = %v, want %v", got, tt.wantHits)
+			}
+			if last != tt.wantLast {
+				t.Errorf("last = %d, want %d", last, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestScanGroupOtherError(t *testing.T) {
+	key := testReaderKey()
+	src := newFakeNews(1, 10)
+	src.post(t, key, 4)
+	src.missing[4] = 503
+	_, last, err := scanGroup(src, "alt.test", 2, []esub{key})
+	if err == nil {
+		t.Fatal("scanGroup ignored a 503")
+	}
+	if last != 2 {
+		t.Errorf("last = %d after a failed scan, want 2", last)
+	}
+}
+
+func TestScanGroupState(t *testing.T) {
+	key := testReaderKey()
+	src := newFakeNews(1, 10)
+	src.post(t, key, 7)
+	state := make(map[string]int)
+
+	scan := func() []int {
+		t.Helper()
+		matches, last, err := scanGroup(src, "alt.test", state["alt.test"], []esub{key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		state["alt.test"] = last
+		return articleNumbers(matches)
+	}
+
+	if got := scan(); fmt.Sprint(got) != "[7]" {
+		t.Fatalf("first scan = %v, want [7]", got)
+	}
+	if got := scan(); len(got) != 0 {
+		t.Fatalf("rescan without new articles = %v, want none", got)
+	}
+	src.fetched = nil
+	src.post(t, key, 12)
+	src.high = 15
+	if got := scan(); fmt.Sprint(got) != "[12]" {
+		t.Fatalf("scan after new articles = %v, want [12]", got)
+	}
+	if src.overRange != [2]int{11, 15} {
+		t.Errorf("overview range = %v, want [11 15]", src.overRange)
+	}
+	if fmt.Sprint(src.fetched) != "[12]" {
+		t.Errorf("fetched %v, want only [12]", src.fetched)
+	}
+	if state["alt.test"] != 15 {
+		t.Errorf("state = %d, want 15", state["alt.test"])
+	}
+}
+
+// nntpStandIn is a scripted NNTP server on a loopback port. Each command
+// line is answered with the response registered for it, or 500 when there
+// is none. Responses are written as given, CRLFs and dot-stuffing included.
+type nntpStandIn struct {
+	ln        net.Listener
+	responses map[string]string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func startNNTPStandIn(t *testing.T, responses map[string]string) *nntpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &nntpStandIn{ln: ln, responses: responses}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *nntpStandIn) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("200 stand-in ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+		response, ok := s.responses[line]
+		switch {
+		case line == "QUIT":
+			response = "205 bye\r\n"
+		case !ok:
+			response = "500 unknown command\r\n"
+		}
+		text.W.WriteString(response)
+		text.W.Flush()
+		if line == "QUIT" {
+			return
+		}
+	}
+}
+
+func (s *nntpStandIn) dial(t *testing.T) *nntpClient {
+	t.Helper()
+	host, port, err := net.SplitHostPort(s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := dialNNTP(proxy.Direct, host, port, nntpSecurityNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (s *nntpStandIn) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func TestNNTPGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		low, high int
+		ok        bool
+	}{
+		{"counts", "211 6 5 10 alt.test\r\n", 5, 10, true},
+		{"empty group", "211 0 11 10 alt.test\r\n", 11, 10, true},
+		{"malformed", "211 alt.test\r\n", 0, 0, false},
+		{"no such group", "411 no such group\r\n", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startNNTPStandIn(t, map[string]string{"GROUP alt.test": tt.response})
+			c := s.dial(t)
+			defer c.quit()
+			low, high, err := c.group("alt.test")
+			if tt.ok != (err == nil) {
+				t.Fatalf("group() error = %v", err)
+			}
+			if low != tt.low || high != tt.high {
+				t.Errorf("group() = %d, %d, want %d, %d", low, high, tt.low, tt.high)
+			}
+		})
+	}
+}
+
+func TestNNTPOverview(t *testing.T) {
+	over := "224 overview follows\r\n" +
+		"5\tfirst\ta@example.org\tMon, 1 Jan 2024 00:00:00 +0000\t<1@example.org>\t\t10\t1\r\n" +
+		"bogus line\r\n" +
+		"x\tno number\ta\td\t<x@example.org>\r\n" +
+		"7\tsecond\tb@example.org\tTue, 2 Jan 2024 00:00:00 +0000\t<2@example.org>\t\t10\t1\r\n" +
+		".\r\n"
+	want := []newsOverview{
+		{Number: 5, Subject: "first", From: "a@example.org", Date: "Mon, 1 Jan 2024 00:00:00 +0000", MessageID: "<1@example.org>"},
+		{Number: 7, Subject: "second", From: "b@example.org", Date: "Tue, 2 Jan 2024 00:00:00 +0000", MessageID: "<2@example.org>"},
+	}
+	tests := []struct {
+		name      string
+		responses map[string]string
+		commands  string
+	}{
+		{"OVER", map[string]string{"OVER 5-7": over}, "OVER 5-7"},
+		{"XOVER fallback", map[string]string{"XOVER 5-7": over}, "OVER 5-7,XOVER 5-7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startNNTPStandIn(t, tt.responses)
+			c := s.dial(t)
+			got, err := c.overview(5, 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.quit()
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("overview() = %+v, want %+v", got, want)
+			}
+			commands := strings.Join(s.received(), ",")
+			if commands != tt.commands+",QUIT" {
+				t.Errorf("commands sent: %s", commands)
+			}
+		})
+	}
+}
+
+func TestNNTPArticle(t *testing.T) {
+	s := startNNTPStandIn(t, map[string]string{
+		"ARTICLE 3": "220 3 <3@example.org> article\r\n" +
+			"Subject: dots\r\n" +
+			"\r\n" +
+			"..leading dot\r\n" +
+			"...\r\n" +
+			"last line\r\n" +
+			".\r\n",
+		"ARTICLE 4": "423 no such article number\r\n",
+		"ARTICLE 5": "430 no such article\r\n",
+	})
+	c := s.dial(t)
+	defer c.quit()
+
+	raw, err := c.article(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Subject: dots\r\n\r\n.leading dot\r\n..\r\nlast line\r\n"; raw != want {
+		t.Errorf("article() = %q, want %q", raw, want)
+	}
+	for n, code := range map[int]int{4: 423, 5: 430} {
+		_, err := c.article(n)
+		tpErr, ok := err.(*textproto.Error)
+		if !ok {
+			t.Fatalf("article(%d) error = %v, want a textproto.Error", n, err)
+		}
+		if tpErr.Code != code {
+			t.Errorf("article(%d) code = %d, want %d", n, tpErr.Code, code)
+		}
+	}
+}
+
+func TestScanGroupNNTP(t *testing.T) {
+	key := testReaderKey()
+	subject, err := key.esubgen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	overLine := func(n int, subject string) string {
+		return fmt.Sprintf("%d\t%s\tnobody@example.org\tMon, 1 Jan 2024 00:00:00 +0000\t<%d@example.org>\t\t10\t1\r\n", n, subject, n)
+	}
+	s := startNNTPStandIn(t, map[string]string{
+		"GROUP alt.test": "211 4 1 4 alt.test\r\n",
+		"OVER 2-4": "224 overview follows\r\n" +
+			overLine(2, subject) + overLine(3, "unrelated") + overLine(4, subject) + ".\r\n",
+		"ARTICLE 2": "430 no such article\r\n",
+		"ARTICLE 4": "220 4 <4@example.org> article\r\nSubject: " + subject + "\r\n\r\nfor you\r\n.\r\n",
+	})
+	c := s.dial(t)
+	matches, last, err := scanGroup(c, "alt.test", 1, []esub{key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.quit()
+	if last != 4 || len(matches) != 1 || matches[0].Number != 4 {
+		t.Fatalf("scanGroup = %+v, %d", matches, last)
+	}
+	if !strings.HasSuffix(matches[0].Raw, "\r\n\r\nfor you\r\n") {
+		t.Errorf("article body = %q", matches[0].Raw)
+	}
+	if got := strings.Join(s.received(), ","); got != "GROUP alt.test,OVER 2-4,ARTICLE 2,ARTICLE 4,QUIT" {
+		t.Errorf("commands sent: %s", got)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	nntpSecurityStartTLS = "starttls"
+	nntpSecurityTLS      = "nntps"
+	nntpSecurityNone     = "none"
+)
+
+var nntpSecurities = []string{nntpSecurityStartTLS, nntpSecurityTLS, nntpSecurityNone}
+
+// nntpClient is a minimal RFC 3977 client, enough to authenticate and
+// post articles.
+type nntpClient struct {
+	conn net.Conn
+	text *textproto.Conn
+	host string
+	tls  bool
+}
+
+func nntpDefaultPort(security string) string {
+	if security == nntpSecurityTLS {
+		return "563"
+	}
+	return "119"
+}
+
+// dialNNTP connects to host:port through dialer and reads the greeting,
+// upgrading the connection to TLS as security asks.
+func dialNNTP(dialer proxy.Dialer, host, port, security string) (*nntpClient, error) {
+	if port == "" {
+		port = nntpDefaultPort(security)
+	}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	c := &nntpClient{conn: conn, host: host}
+	if security == nntpSecurityTLS {
+		c.startTLS()
+	}
+	c.text = textproto.NewConn(c.conn)
+
+	if _, _, err := c.text.ReadCodeLine(20); err != nil {
+		c.conn.Close()
+		return nil, fmt.Errorf("greeting: %v", err)
+	}
+
+	if security == nntpSecurityStartTLS {
+		if _, err := c.cmd(382, "STARTTLS"); err != nil {
+			c.conn.Close()
+			return nil, fmt.Errorf("STARTTLS: %v", err)
+		}
+		c.startTLS()
+		c.text = textproto.NewConn(c.conn)
+	}
+	return c, nil
+}
+
+func (c *nntpClient) startTLS() {
+	c.conn = tls.Client(c.conn, &tls.Config{ServerName: c.host, InsecureSkipVerify: true})
+	c.tls = true
+}
+
+// cmd sends a command line and reads a response, which must carry the
+// expected code.
+func (c *nntpClient) cmd(expect int, format string, args ...interface{}) (string, error) {
+	line, err := commandLine(format, args...)
+	if err != nil {
+		return "", err
+	}
+	if err := c.text.PrintfLine("%s", line); err != nil {
+		return "", err
+	}
+	_, msg, err := c.text.ReadCodeLine(expect)
+	return msg, err
+}
+
+// auth runs AUTHINFO USER/PASS. Like smtp.PlainAuth it refuses to send
+// the password over a cleartext connection, except to localhost or an
+// onion service, where Tor already encrypts the stream.
+func (c *nntpClient) auth(username string, password []byte) error {
+	if !c.tls && !isLocalhost(c.host) && !strings.HasSuffix(c.host, ".onion") {
+		return errors.New("unencrypted connection")
+	}
+	if err := checkSecret("password", password); err != nil {
+		return err
+	}
+	user, err := commandLine("AUTHINFO USER %s", username)
+	if err != nil {
+		return err
+	}
+	if err := c.text.PrintfLine("%s", user); err != nil {
+		return err
+	}
+	code, msg, err := c.text.ReadCodeLine(0)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case 281:
+		return nil
+	case 381:
+	default:
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+
+	line := make([]byte, 0, len("AUTHINFO PASS ")+len(password)+2)
+	line = append(line, "AUTHINFO PASS "...)
+	line = append(line, password...)
+	line = append(line, "\r\n"...)
+	lockMemory(line)
+	_, err = c.text.W.Write(line)
+	wipeBytes(line)
+	if err != nil {
+		return err
+	}
+	if err := c.text.W.Flush(); err != nil {
+		return err
+	}
+	_, _, err = c.text.ReadCodeLine(281)
+	return err
+}
+
+// post sends a CRLF article with POST.
+func (c *nntpClient) post(article string) error {
+	if err := checkHeaderBlock(article); err != nil {
+		return err
+	}
+	if _, err := c.cmd(340, "POST"); err != nil {
+		return err
+	}
+	w := c.text.DotWriter()
+	if _, err := w.Write([]byte(article)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	_, _, err := c.text.ReadCodeLine(240)
+	return err
+}
+
+func (c *nntpClient) quit() {
+	c.cmd(205, "QUIT")
+	c.conn.Close()
+}
+
+// postNNTP checks a finished article for identifying headers and posts
+// it to the profile's news server through the SOCKS5 proxy.
+func (g *GUI) postNNTP(article string) {
+	g.checkHeaders(article, func() {
+		g.sendNNTP(article)
+	})
+}
+
+// sendNNTP runs the NNTP session for postNNTP, after the header lint.
+func (g *GUI) sendNNTP(article string) {
+	article, err := g.finalizeHeaders(article)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Refusing to post: %v", err), g.window)
+		return
+	}
+
+	fyne.Do(func() {
+		g.statusLabel.SetText("Starting NNTP session...")
+	})
+
+	host := strings.TrimSpace(g.nntpHostEnt.Text)
+	port := strings.TrimSpace(g.nntpPortEnt.Text)
+	security := g.nntpSecuritySelect.Selected
+	username := g.nntpUsernameEnt.Text
+	password := secretBytes(g.nntpPasswordEnt.Text)
+
+	go func() {
+		defer wipeBytes(password)
+
+		updateStatus := func(text string) {
+			fyne.Do(func() {
+				g.statusLabel.SetText(text)
+			})
+		}
+
+		showError := func(err error) {
+			fyne.Do(func() {
+				dialog.ShowError(err, g.window)
+			})
+		}
+
+		if host == "" {
+			showError(fmt.Errorf("No NNTP server configured"))
+			return
+		}
+
+		updateStatus("Connecting to SOCKS proxy...")
+		dialer, err := proxy.SOCKS5("tcp", "127.0.0.1:"+g.socksPortEnt.Text, nil, proxy.Direct)
+		if err != nil {
+			updateStatus("SOCKS Error: " + err.Error())
+			showError(fmt.Errorf("SOCKS5 error: %v", err))
+			return
+		}
+
+		updateStatus("Connecting to NNTP server...")
+		client, err := dialNNTP(dialer, host, port, security)
+		if err != nil {
+			updateStatus("Connection Error: " + err.Error())
+			showError(fmt.Errorf("Connection failed: %v", err))
+			return
+		}
+		defer client.quit()
+
+		if username != "" && len(password) > 0 {
+			updateStatus("Authenticating...")
+			if err := client.auth(username, password); err != nil {
+				updateStatus("Auth Error: " + err.Error())
+				showError(fmt.Errorf("Auth failed: %v", err))
+				return
+			}
+		}
+
+		updateStatus("Sending POST...")
+		if err := client.post(article); err != nil {
+			updateStatus("POST Error: " + err.Error())
+			showError(fmt.Errorf("POST failed: %v", err))
+			return
+		}
+
+		updateStatus("Article posted successfully")
+	}()
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const (
+	nymFile   = "nyms.json"
+	nymFormat = "mmg-nyms-v1"
+)
+
+const (
+	nymCreate = "create"
+	nymModify = "modify"
+	nymDelete = "delete"
+)
+
+// nymAccount is the locally kept state of one nym server account.
+type nymAccount struct {
+	Nym          string `json:"nym"`
+	Server       string `json:"server"`
+	SendFrom     string `json:"send_from"`
+	Name         string `json:"name"`
+	Options      string `json:"options"`
+	EsubKey      string `json:"esub_key"`
+	HashcashBits string `json:"hashcash_bits"`
+	ReplyBlock   string `json:"reply_block"`
+	Status       string `json:"status"`
+}
+
+var nymOption = regexp.MustCompile(`^[+-][a-z]+$`)
+
+// nymRequestBody builds the plaintext of a nym server config request in
+// the classic "Config:" format, terminated by "**".
+func nymRequestBody(a nymAccount, kind, publicKey string) (string, error) {
+	local, _, ok := strings.Cut(a.Nym, "@")
+	if !ok || local == "" {
+		return "", fmt.Errorf("nym address %q is invalid", a.Nym)
+	}
+	if err := checkLine("nym address", a.Nym); err != nil {
+		return "", err
+	}
+	if err := checkLine("esub key", a.EsubKey); err != nil {
+		return "", err
+	}
+
+	var commands []string
+	switch kind {
+	case nymCreate, nymDelete:
+		commands = append(commands, kind)
+	case nymModify:
+	default:
+		return "", fmt.Errorf("unknown nym request %q", kind)
+	}
+	if kind != nymDelete {
+		for _, opt := range strings.Fields(a.Options) {
+			if !nymOption.MatchString(opt) {
+				return "", fmt.Errorf("invalid nym option %q", opt)
+			}
+			commands = append(commands, opt)
+		}
+		if a.Name != "" {
+			commands = append(commands, fmt.Sprintf("name=%q", a.Name))
+		}
+	}
+	if len(commands) == 0 {
+		return "", fmt.Errorf("nothing to change")
+	}
+
+	var b strings.Builder
+	b.WriteString("Config:\r\n")
+	b.WriteString("From: " + local + "\r\n")
+	b.WriteString("Nym-Commands: " + strings.Join(commands, " ") + "\r\n")
+	if kind != nymDelete {
+		if a.EsubKey != "" {
+			b.WriteString("Esub-Key: " + a.EsubKey + "\r\n")
+		}
+		if kind == nymCreate {
+			if publicKey == "" {
+				return "", fmt.Errorf("a create request needs the nym's public key")
+			}
+			b.WriteString("Public-Key:\r\n" + publicKey)
+		}
+		if reply := strings.TrimSpace(a.ReplyBlock); reply != "" {
+			b.WriteString("Reply-Block:\r\n" + crlf(reply) + "\r\n")
+		} else if kind == nymCreate {
+			return "", fmt.Errorf("a create request needs a reply block")
+		}
+	}
+	b.WriteString("**\r\n")
+	return b.String(), nil
+}
+
+func armoredPublicKey(e *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := e.Serialize(w); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return crlf(buf.String()) + "\r\n", nil
+}
+
+func (g *GUI) loadNyms() {
+	var nyms []nymAccount
+	locked, err := g.readStore(nymFile, nymFormat, &nyms)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to load nym accounts: %v", err), g.window)
+		return
+	}
+	if locked {
+		g.showUnlockDialog(storeCheck(nymFile, nymFormat), g.loadNyms)
+		return
+	}
+	g.nyms = nyms
+	g.nymsLoaded = true
+	g.nymList.Refresh()
+}
+
+func (g *GUI) saveNyms() error {
+	if !g.nymsLoaded {
+		return fmt.Errorf("Nym accounts are locked")
+	}
+	return g.writeStore(nymFile, nymFormat, g.nyms)
+}
+
+// sendNymRequest builds a request of kind for a, signs it with the nym's
+// key, encrypts it to the server's config key and sends it through the
+// current profile.
+func (g *GUI) sendNymRequest(a nymAccount, kind string) {
+	if !isValidEmail(a.SendFrom) || !isValidEmail(a.Server) {
+		dialog.ShowError(fmt.Errorf("Invalid 'Send from' or server address"), g.window)
+		return
+	}
+	dir, err := appConfigDir()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+		return
+	}
+	public, _, err := loadPGPKeyrings(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to read keyring: %v", err), g.window)
+		return
+	}
+	var publicKey string
+	if kind == nymCreate {
+		key := pgpEntityFor(public, a.Nym)
+		if key == nil {
+			dialog.ShowError(fmt.Errorf("No PGP key for %s; import the nym's key first", a.Nym), g.window)
+			return
+		}
+		if publicKey, err = armoredPublicKey(key); err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+	}
+	if a.ReplyBlock, err = g.resolveReplyBlocks(a.ReplyBlock); err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	body, err := nymRequestBody(a, kind, publicKey)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+
+	header := "From: " + a.SendFrom + "\nTo: " + a.Server + "\n"
+	if bits := strings.TrimSpace(a.HashcashBits); bits != "" {
+		stamp, err := mintHashcash(bits, a.Server)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to generate hashcash: %v", err), g.window)
+			return
+		}
+		header += "X-Hashcash: " + strings.TrimSpace(stamp) + "\n"
+	}
+	from, to, raw, err := g.prepareMessage(header + "\n" + strings.ReplaceAll(body, "\r\n", "\n"))
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.applyPGP(raw, a.Nym, pgpModeEncryptSign, pgpFormatInline, func(message string) {
+		g.deliver(from, to, message, func() {
+			g.setNymStatus(a.Nym, kind+" sent "+time.Now().UTC().Format("2006-01-02"))
+		})
+	})
+}
+
+func (g *GUI) setNymStatus(nym, status string) {
+	for i := range g.nyms {
+		if g.nyms[i].Nym == nym {
+			g.nyms[i].Status = status
+		}
+	}
+	if err := g.saveNyms(); err != nil {
+		dialog.ShowError(err, g.window)
+	}
+	g.nymList.Refresh()
+	if g.selectedNym >= 0 && g.selectedNym < len(g.nyms) {
+		g.nymStatusLabel.SetText(g.nyms[g.selectedNym].Status)
+	}
+}
+
+func (g *GUI) buildNymTab() *fyne.Container {
+	g.selectedNym = -1
+	nymEntry := widget.NewEntry()
+	nymEntry.SetPlaceHolder("alice@nym.example")
+	serverEntry := widget.NewEntry()
+	serverEntry.SetPlaceHolder("config@nym.example")
+	sendFromEntry := widget.NewEntry()
+	nameEntry := widget.NewEntry()
+	optionsEntry := widget.NewEntry()
+	optionsEntry.SetPlaceHolder("+acksend +signsend +cryptrecv")
+	esubEntry := widget.NewEntry()
+	hashcashEntry := widget.NewEntry()
+	replyEntry := widget.NewMultiLineEntry()
+	replyEntry.SetPlaceHolder("Reply block, or {{replyblock:name}} from the Reply Blocks tab")
+	replyEntry.TextStyle = fyne.TextStyle{Monospace: true}
+	g.nymStatusLabel = widget.NewLabel("")
+
+	fill := func(a nymAccount) {
+		nymEntry.SetText(a.Nym)
+		serverEntry.SetText(a.Server)
+		sendFromEntry.SetText(a.SendFrom)
+		nameEntry.SetText(a.Name)
+		optionsEntry.SetText(a.Options)
+		esubEntry.SetText(a.EsubKey)
+		hashcashEntry.SetText(a.HashcashBits)
+		replyEntry.SetText(a.ReplyBlock)
+		g.nymStatusLabel.SetText(a.Status)
+	}
+	current := func() nymAccount {
+		a := nymAccount{
+			Nym:          strings.TrimSpace(nymEntry.Text),
+			Server:       strings.TrimSpace(serverEntry.Text),
+			SendFrom:     strings.TrimSpace(sendFromEntry.Text),
+			Name:         nameEntry.Text,
+			Options:      optionsEntry.Text,
+			EsubKey:      esubEntry.Text,
+			HashcashBits: strings.TrimSpace(hashcashEntry.Text),
+			ReplyBlock:   replyEntry.Text,
+		}
+		for _, n := range g.nyms {
+			if n.Nym == a.Nym {
+				a.Status = n.Status
+			}
+		}
+		return a
+	}
+
+	g.nymList = widget.NewList(
+		func() int { return len(g.nyms) },
+		func() fyne.CanvasObject { return widget.NewLabel("nym") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(g.nyms[id].Nym)
+		},
+	)
+	g.nymList.OnSelected = func(id widget.ListItemID) {
+		g.selectedNym = id
+		fill(g.nyms[id])
+	}
+
+	save := func() {
+		a := current()
+		if !isValidEmail(a.Nym) {
+			dialog.ShowError(fmt.Errorf("Invalid nym address"), g.window)
+			return
+		}
+		found := false
+		for i, n := range g.nyms {
+			if n.Nym == a.Nym {
+				g.nyms[i] = a
+				found = true
+			}
+		}
+		if !found {
+			g.nyms = append(g.nyms, a)
+		}
+		if err := g.saveNyms(); err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		g.nymList.Refresh()
+	}
+	send := func(kind string) func() {
+		return func() {
+			a := current()
+			if kind != nymDelete {
+				g.sendNymRequest(a, kind)
+				return
+			}
+			dialog.ShowConfirm("Delete Nym", "Ask the server to delete "+a.Nym+"?", func(ok bool) {
+				if ok {
+					g.sendNymRequest(a, kind)
+				}
+			}, g.window)
+		}
+	}
+
+	controls := container.NewHBox(
+		widget.NewButton("New", func() {
+			g.nymList.UnselectAll()
+			g.selectedNym = -1
+			fill(nymAccount{Options: "+acksend +signsend", EsubKey: g.esubKeyEntry.Text, HashcashBits: g.hashcashBitsEntry.Text})
+		}),
+		widget.NewButton("Save", save),
+		widget.NewButton("Forget", func() {
+			if g.selectedNym < 0 || g.selectedNym >= len(g.nyms) {
+				dialog.ShowError(fmt.Errorf("No nym selected"), g.window)
+				return
+			}
+			g.nyms = append(g.nyms[:g.selectedNym], g.nyms[g.selectedNym+1:]...)
+			if err := g.saveNyms(); err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			g.selectedNym = -1
+			g.nymList.UnselectAll()
+			g.nymList.Refresh()
+			fill(nymAccount{})
+		}),
+		widget.NewButton("Send Create", send(nymCreate)),
+		widget.NewButton("Send Modify", send(nymModify)),
+		widget.NewButton("Send Delete", send(nymDelete)),
+	)
+
+	form := widget.NewForm(
+		widget.NewFormItem("Nym", nymEntry),
+		widget.NewFormItem("Config address", serverEntry),
+		widget.NewFormItem("Send from", sendFromEntry),
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Options", optionsEntry),
+		widget.NewFormItem("esub Key", esubEntry),
+		widget.NewFormItem("Hashcash Bits", hashcashEntry),
+		widget.NewFormItem("Status", g.nymStatusLabel),
+	)
+
+	g.loadNyms()
+
+	return container.NewBorder(
+		nil, nil,
+		container.NewBorder(widget.NewLabel("Nym Accounts"), nil, nil, nil, g.nymList),
+		nil,
+		container.NewBorder(container.NewVBox(form, controls), nil, nil, nil, container.NewScroll(replyEntry)),
+	)
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const (
+	pubringFile = "pubring.gpg"
+	secringFile = "secring.gpg"
+)
+
+const (
+	pgpModeOff         = "off"
+	pgpModeEncrypt     = "encrypt"
+	pgpModeSign        = "sign"
+	pgpModeEncryptSign = "encrypt+sign"
+)
+
+var pgpModes = []string{pgpModeOff, pgpModeEncrypt, pgpModeSign, pgpModeEncryptSign}
+
+const (
+	pgpFormatInline = "inline"
+	pgpFormatMIME   = "pgp/mime"
+)
+
+var pgpFormats = []string{pgpFormatInline, pgpFormatMIME}
+
+// pgpConfig pins the algorithms used for everything mmg encrypts or
+// signs. micalg in PGP/MIME signatures must agree with DefaultHash.
+var pgpConfig = &packet.Config{
+	DefaultHash:   crypto.SHA256,
+	DefaultCipher: packet.CipherAES256,
+}
+
+const pgpMicalg = "pgp-sha256"
+
+// readKeyData accepts binary key material or one or more ASCII-armored
+// key blocks and returns the binary packets.
+func readKeyData(data []byte) ([]byte, error) {
+	text := string(data)
+	start := strings.Index(text, "-----BEGIN PGP ")
+	if start < 0 {
+		return data, nil
+	}
+	var bin bytes.Buffer
+	for start >= 0 {
+		text = text[start:]
+		block, err := armor.Decode(strings.NewReader(text))
+		if err != nil {
+			return nil, err
+		}
+		if block.Type != openpgp.PublicKeyType && block.Type != openpgp.PrivateKeyType {
+			return nil, fmt.Errorf("unexpected armor block %q", block.Type)
+		}
+		if _, err := io.Copy(&bin, block.Body); err != nil {
+			return nil, err
+		}
+		end := strings.Index(text, "-----END PGP ")
+		if end < 0 {
+			break
+		}
+		text = text[end+len("-----END PGP "):]
+		start = strings.Index(text, "-----BEGIN PGP ")
+	}
+	return bin.Bytes(), nil
+}
+
+func readKeyringFile(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// loadPGPKeyrings reads mmg's public and secret keyrings from dir.
+// Missing keyrings are returned empty.
+func loadPGPKeyrings(dir string) (public, secret openpgp.EntityList, err error) {
+	public, err = readKeyringFile(filepath.Join(dir, pubringFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", pubringFile, err)
+	}
+	secret, err = readKeyringFile(filepath.Join(dir, secringFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", secringFile, err)
+	}
+	return public, secret, nil
+}
+
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// importPGPKeys adds the keys in data to the keyrings in dir. Keys whose
+// fingerprint is already in a keyring are skipped. Secret keys are stored
+// without re-signing or decrypting, so they stay protected by their own
+// passphrase; only entities that carry secret material go to secring.gpg.
+func importPGPKeys(dir string, data []byte) (public, secret int, err error) {
+	bin, err := readKeyData(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	entities, err := openpgp.ReadKeyRing(bytes.NewReader(bin))
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(entities) == 0 {
+		return 0, 0, fmt.Errorf("no keys found")
+	}
+
+	knownPublic, err := readKeyringFile(filepath.Join(dir, pubringFile))
+	if err != nil {
+		return 0, 0, err
+	}
+	knownSecret, err := readKeyringFile(filepath.Join(dir, secringFile))
+	if err != nil {
+		return 0, 0, err
+	}
+	seen := make(map[string]bool)
+	for _, e := range knownPublic {
+		seen[string(e.PrimaryKey.Fingerprint)] = true
+	}
+	seenSecret := make(map[string]bool)
+	for _, e := range knownSecret {
+		seenSecret[string(e.PrimaryKey.Fingerprint)] = true
+	}
+
+	var pub, sec bytes.Buffer
+	for _, e := range entities {
+		fp := string(e.PrimaryKey.Fingerprint)
+		if e.PrivateKey != nil && !seenSecret[fp] {
+			if err := e.SerializePrivateWithoutSigning(&sec, pgpConfig); err != nil {
+				return 0, 0, err
+			}
+			seenSecret[fp] = true
+			secret++
+		}
+		if seen[fp] {
+			continue
+		}
+		if err := e.Serialize(&pub); err != nil {
+			return 0, 0, err
+		}
+		seen[fp] = true
+		public++
+	}
+	if pub.Len() > 0 {
+		if err := appendFile(filepath.Join(dir, pubringFile), pub.Bytes()); err != nil {
+			return 0, 0, err
+		}
+	}
+	if sec.Len() > 0 {
+		if err := appendFile(filepath.Join(dir, secringFile), sec.Bytes()); err != nil {
+			return 0, 0, err
+		}
+	}
+	return public, secret, nil
+}
+
+// pgpEntityFor returns the first key in list with a user ID for addr.
+func pgpEntityFor(list openpgp.EntityList, addr string) *openpgp.Entity {
+	addr = strings.ToLower(strings.Trim(addr, "<>"))
+	for _, e := range list {
+		for _, id := range e.Identities {
+			if strings.ToLower(id.UserId.Email) == addr {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// pgpSignerFor returns the first key in list for addr that carries its
+// secret part.
+func pgpSignerFor(list openpgp.EntityList, addr string) *openpgp.Entity {
+	addr = strings.ToLower(strings.Trim(addr, "<>"))
+	for _, e := range list {
+		if e.PrivateKey == nil {
+			continue
+		}
+		for _, id := range e.Identities {
+			if strings.ToLower(id.UserId.Email) == addr {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// pgpRecipients picks a public key for every address and names the
+// addresses it could not find a key for.
+func pgpRecipients(list openpgp.EntityList, addrs []string) (openpgp.EntityList, error) {
+	var keys openpgp.EntityList
+	var missing []string
+	for _, addr := range addrs {
+		e := pgpEntityFor(list, addr)
+		if e == nil {
+			missing = append(missing, addr)
+			continue
+		}
+		keys = append(keys, e)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no public key for %s", strings.Join(missing, ", "))
+	}
+	return keys, nil
+}
+
+// recipientAddresses collects the bare addresses from the To and Cc
+// fields of a header block.
+func recipientAddresses(header string) []string {
+	var addrs []string
+	for _, f := range headerFields(header) {
+		name := fieldName(f)
+		if name != "to" && name != "cc" {
+			continue
+		}
+		list, err := mail.ParseAddressList(fieldValue(f))
+		if err != nil {
+			continue
+		}
+		for _, a := range list {
+			addrs = append(addrs, a.Address)
+		}
+	}
+	return addrs
+}
+
+// decryptSigner unlocks the private key and subkeys of e.
+func decryptSigner(e *openpgp.Entity, passphrase []byte) error {
+	if e.PrivateKey != nil && e.PrivateKey.Encrypted {
+		if err := e.PrivateKey.Decrypt(passphrase); err != nil {
+			return err
+		}
+	}
+	for _, sub := range e.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			if err := sub.PrivateKey.Decrypt(passphrase); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+func pgpEncrypt(plaintext []byte, to openpgp.EntityList, signer *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	aw, err := armor.Encode(&buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", err
+	}
+	w, err := openpgp.Encrypt(aw, to, signer, &openpgp.FileHints{}, pgpConfig)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := aw.Close(); err != nil {
+		return "", err
+	}
+	return crlf(buf.String()) + "\r\n", nil
+}
+
+func pgpClearsign(text []byte, signer *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, signer.PrivateKey, pgpConfig)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(text); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return crlf(buf.String()) + "\r\n", nil
+}
+
+func pgpDetachSign(text []byte, signer *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSignText(&buf, signer, bytes.NewReader(text), pgpConfig); err != nil {
+		return "", err
+	}
+	return crlf(buf.String()) + "\r\n", nil
+}
+
+func mimeBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "=_" + hex.EncodeToString(b), nil
+}
+
+// pgpOptions describes the OpenPGP step applied before a message is sent.
+type pgpOptions struct {
+	mode       string
+	format     string
+	recipients openpgp.EntityList
+	signer     *openpgp.Entity
+}
+
+// pgpTransform encrypts and/or signs the CRLF message raw as inline PGP
+// or PGP/MIME (RFC 3156) and returns the rewritten message.
+func pgpTransform(raw string, opts pgpOptions) (string, error) {
+	header, body := splitMessage(raw)
+	encrypt := opts.mode == pgpModeEncrypt || opts.mode == pgpModeEncryptSign
+	sign := opts.mode == pgpModeSign || opts.mode == pgpModeEncryptSign
+	var signer *openpgp.Entity
+	if sign {
+		signer = opts.signer
+		if signer == nil || signer.PrivateKey == nil {
+			return "", fmt.Errorf("no secret key to sign with")
+		}
+	}
+
+	if opts.format == pgpFormatInline {
+		var (
+			out string
+			err error
+		)
+		if encrypt {
+			out, err = pgpEncrypt([]byte(body), opts.recipients, signer)
+		} else {
+			// The transfer encoding is settled before signing; deliver
+			// must not re-encode what the signature covers.
+			var prepared string
+			if prepared, err = prepareSignedBody(raw); err != nil {
+				return "", err
+			}
+			header, body = splitMessage(prepared)
+			out, err = pgpClearsign([]byte(body), signer)
+		}
+		if err != nil {
+			return "", err
+		}
+		return joinMessage(header, out), nil
+	}
+
+	// PGP/MIME: the content fields move into the protected inner entity,
+	// which RFC 3156 wants 7bit-clean before it is signed.
+	header, body = splitMessage(prepareBody(raw, false))
+	fields := headerFields(header)
+	var inner []string
+	for _, f := range fields {
+		switch fieldName(f) {
+		case "content-type", "content-transfer-encoding", "content-disposition":
+			inner = append(inner, f)
+		}
+	}
+	if len(inner) == 0 {
+		inner = []string{"Content-Type: text/plain; charset=utf-8"}
+	}
+	entity := joinMessage(strings.Join(inner, "\r\n"), body)
+	outer := removeFields(fields, "content-type", "content-transfer-encoding", "content-disposition", "mime-version")
+
+	boundary, err := mimeBoundary()
+	if err != nil {
+		return "", err
+	}
+	var contentType, multipartBody string
+	if encrypt {
+		armored, err := pgpEncrypt([]byte(entity), opts.recipients, signer)
+		if err != nil {
+			return "", err
+		}
+		contentType = fmt.Sprintf("multipart/encrypted; protocol=\"application/pgp-encrypted\";\r\n boundary=\"%s\"", boundary)
+		multipartBody = "This is an OpenPGP/MIME encrypted message (RFC 3156).\r\n" +
+			"--" + boundary + "\r\n" +
+			"Content-Type: application/pgp-encrypted\r\n\r\n" +
+			"Version: 1\r\n\r\n" +
+			"--" + boundary + "\r\n" +
+			"Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n\r\n" +
+			armored +
+			"--" + boundary + "--\r\n"
+	} else {
+		signature, err := pgpDetachSign([]byte(entity), signer)
+		if err != nil {
+			return "", err
+		}
+		contentType = fmt.Sprintf("multipart/signed; micalg=%s; protocol=\"application/pgp-signature\";\r\n boundary=\"%s\"", pgpMicalg, boundary)
+		multipartBody = "This is an OpenPGP/MIME signed message (RFC 3156).\r\n" +
+			"--" + boundary + "\r\n" +
+			entity + "\r\n" +
+			"--" + boundary + "\r\n" +
+			"Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n\r\n" +
+			signature +
+			"--" + boundary + "--\r\n"
+	}
+	outer = append(outer, "MIME-Version: 1.0", "Content-Type: "+contentType)
+	return joinMessage(strings.Join(outer, "\r\n"), multipartBody), nil
+}
+
+// applyPGP runs an OpenPGP step over raw and passes the result to next.
+// It asks for the signing key's passphrase when needed.
+func (g *GUI) applyPGP(raw, from, mode, format string, next func(string)) {
+	if mode == "" || mode == pgpModeOff {
+		next(raw)
+		return
+	}
+	dir, err := appConfigDir()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+		return
+	}
+	public, secret, err := loadPGPKeyrings(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to read keyring: %v", err), g.window)
+		return
+	}
+
+	opts := pgpOptions{mode: mode, format: format}
+	if opts.format == "" {
+		opts.format = pgpFormatInline
+	}
+	if mode == pgpModeEncrypt || mode == pgpModeEncryptSign {
+		header, _ := splitMessage(raw)
+		opts.recipients, err = pgpRecipients(public, recipientAddresses(header))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("PGP: %v", err), g.window)
+			return
+		}
+	}
+
+	run := func() {
+		out, err := pgpTransform(raw, opts)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("PGP failed: %v", err), g.window)
+			return
+		}
+		next(out)
+	}
+
+	if mode == pgpModeEncrypt {
+		run()
+		return
+	}
+	opts.signer = pgpSignerFor(secret, from)
+	if opts.signer == nil {
+		dialog.ShowError(fmt.Errorf("PGP: no secret key for %s", from), g.window)
+		return
+	}
+	if !opts.signer.PrivateKey.Encrypted {
+		run()
+		return
+	}
+
+	passEntry := widget.NewPasswordEntry()
+	dialog.ShowForm(
+		"PGP Passphrase",
+		"Sign",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Passphrase for "+strings.Trim(from, "<>"), passEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				g.statusLabel.SetText("Sending cancelled.")
+				return
+			}
+			passphrase := secretBytes(passEntry.Text)
+			passEntry.SetText("")
+			err := decryptSigner(opts.signer, passphrase)
+			wipeBytes(passphrase)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to unlock secret key: %v", err), g.window)
+				return
+			}
+			run()
+		},
+		g.window,
+	)
+}
+
+func (g *GUI) importPGPKeyring() {
+	dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if r == nil {
+			return
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to read keyring: %v", err), g.window)
+			return
+		}
+		dir, err := appConfigDir()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+			return
+		}
+		public, secret, err := importPGPKeys(dir, data)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to import keys: %v", err), g.window)
+			return
+		}
+		g.statusLabel.SetText(fmt.Sprintf("Imported %d public and %d secret keys.", public, secret))
+	}, g.window)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func armoredKey(t *testing.T, e *openpgp.Entity, private bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	blockType := openpgp.PublicKeyType
+	if private {
+		blockType = openpgp.PrivateKeyType
+	}
+	w, err := armor.Encode(&buf, blockType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private {
+		err = e.SerializePrivateWithoutSigning(w, nil)
+	} else {
+		err = e.Serialize(w)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return append(buf.Bytes(), '\n')
+}
+
+func TestImportPGPKeys(t *testing.T) {
+	passphrase := []byte("import test passphrase")
+	mine, err := openpgp.NewEntity("Me", "", "me@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mine.EncryptPrivateKeys(passphrase, nil); err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := openpgp.NewEntity("Them", "", "them@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	data := append(armoredKey(t, mine, true), armoredKey(t, theirs, false)...)
+	public, secret, err := importPGPKeys(dir, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public != 2 || secret != 1 {
+		t.Fatalf("imported %d public and %d secret keys, want 2 and 1", public, secret)
+	}
+
+	pub, sec, err := loadPGPKeyrings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pub) != 2 {
+		t.Errorf("pubring holds %d keys, want 2", len(pub))
+	}
+	if len(sec) != 1 || pgpEntityFor(sec, "them@example.org") != nil {
+		t.Fatalf("secring holds %d keys and must only hold the secret one", len(sec))
+	}
+	signer := pgpSignerFor(sec, "me@example.org")
+	if signer == nil {
+		t.Fatal("secret key not found by address")
+	}
+	if !signer.PrivateKey.Encrypted {
+		t.Error("secret key was stored without its passphrase protection")
+	}
+	if err := decryptSigner(signer, passphrase); err != nil {
+		t.Errorf("stored secret key does not unlock: %v", err)
+	}
+
+	before, err := os.ReadFile(filepath.Join(dir, secringFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, secret, err = importPGPKeys(dir, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public != 0 || secret != 0 {
+		t.Errorf("re-import added %d public and %d secret keys, want none", public, secret)
+	}
+	after, err := os.ReadFile(filepath.Join(dir, secringFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("re-import changed secring.gpg")
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	pop3SecuritySTLS = "stls"
+	pop3SecurityTLS  = "pop3s"
+	pop3SecurityNone = "none"
+)
+
+var pop3Securities = []string{pop3SecuritySTLS, pop3SecurityTLS, pop3SecurityNone}
+
+const (
+	pop3UIDLFile   = "pop3-uidl.json"
+	pop3UIDLFormat = "mmg-pop3-uidl-v1"
+)
+
+// pop3Client is a minimal RFC 1939 client with the STLS extension.
+type pop3Client struct {
+	conn net.Conn
+	text *textproto.Conn
+	host string
+	tls  bool
+}
+
+func pop3DefaultPort(security string) string {
+	if security == pop3SecurityTLS {
+		return "995"
+	}
+	return "110"
+}
+
+// dialPOP3 connects to host:port through dialer and reads the greeting,
+// upgrading the connection to TLS as security asks.
+func dialPOP3(dialer proxy.Dialer, host, port, security string) (*pop3Client, error) {
+	if port == "" {
+		port = pop3DefaultPort(security)
+	}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	c := &pop3Client{conn: conn, host: host}
+	if security == pop3SecurityTLS {
+		c.startTLS()
+	}
+	c.text = textproto.NewConn(c.conn)
+
+	if _, err := c.response(); err != nil {
+		c.conn.Close()
+		return nil, fmt.Errorf("greeting: %v", err)
+	}
+	if security == pop3SecuritySTLS {
+		if _, err := c.cmd("STLS"); err != nil {
+			c.conn.Close()
+			return nil, fmt.Errorf("STLS: %v", err)
+		}
+		c.startTLS()
+		c.text = textproto.NewConn(c.conn)
+	}
+	return c, nil
+}
+
+func (c *pop3Client) startTLS() {
+	c.conn = tls.Client(c.conn, &tls.Config{ServerName: c.host, InsecureSkipVerify: true})
+	c.tls = true
+}
+
+// response reads a status line and returns the text after "+OK".
+func (c *pop3Client) response() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(line, "+OK") {
+		return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+	}
+	return "", errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+}
+
+func (c *pop3Client) cmd(format string, args ...interface{}) (string, error) {
+	line, err := commandLine(format, args...)
+	if err != nil {
+		return "", err
+	}
+	if err := c.text.PrintfLine("%s", line); err != nil {
+		return "", err
+	}
+	return c.response()
+}
+
+// auth runs USER/PASS, refusing to do so over a cleartext connection
+// except to localhost or an onion service.
+func (c *pop3Client) auth(username string, password []byte) error {
+	if !c.tls && !isLocalhost(c.host) && !strings.HasSuffix(c.host, ".onion") {
+		return errors.New("unencrypted connection")
+	}
+	if err := checkSecret("password", password); err != nil {
+		return err
+	}
+	if _, err := c.cmd("USER %s", username); err != nil {
+		return err
+	}
+	line := make([]byte, 0, len("PASS ")+len(password)+2)
+	line = append(line, "PASS "...)
+	line = append(line, password...)
+	line = append(line, "\r\n"...)
+	lockMemory(line)
+	_, err := c.text.W.Write(line)
+	wipeBytes(line)
+	if err != nil {
+		return err
+	}
+	if err := c.text.W.Flush(); err != nil {
+		return err
+	}
+	_, err = c.response()
+	return err
+}
+
+// uidl returns the unique ID of every message, keyed by message number.
+func (c *pop3Client) uidl() (map[int]string, error) {
+	if _, err := c.cmd("UIDL"); err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int]string)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ids[n] = fields[1]
+	}
+	return ids, nil
+}
+
+// retr fetches message n as CRLF text.
+func (c *pop3Client) retr(n int) (string, error) {
+	if _, err := c.cmd("RETR %d", n); err != nil {
+		return "", err
+	}
+	data, err := c.text.ReadDotBytes()
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(string(data), "\n", "\r\n"), nil
+}
+
+func (c *pop3Client) dele(n int) error {
+	_, err := c.cmd("DELE %d", n)
+	return err
+}
+
+// quit ends the session; deletions only take effect on a clean QUIT.
+func (c *pop3Client) quit() error {
+	_, err := c.cmd("QUIT")
+	c.conn.Close()
+	return err
+}
+
+// pop3Fetch delivers every message whose UIDL is not in seen into box,
+// deleting it from the server afterwards if del is set. It returns the
+// new seen list: the UIDs still on the server, or on error everything
+// seen or delivered so far, since deletions are rolled back then.
+func pop3Fetch(c *pop3Client, box maildir, seen []string, del bool) (fetched int, uids []string, err error) {
+	ids, err := c.uidl()
+	if err != nil {
+		return 0, seen, fmt.Errorf("UIDL: %v", err)
+	}
+	known := make(map[string]bool)
+	for _, id := range seen {
+		known[id] = true
+	}
+	var numbers []int
+	for n := range ids {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	delivered := append([]string(nil), seen...)
+	for _, n := range numbers {
+		id := ids[n]
+		if !known[id] {
+			raw, err := c.retr(n)
+			if err != nil {
+				return fetched, delivered, fmt.Errorf("RETR %d: %v", n, err)
+			}
+			if _, err := box.deliver([]byte(raw)); err != nil {
+				return fetched, delivered, err
+			}
+			delivered = append(delivered, id)
+			fetched++
+		}
+		if del {
+			if err := c.dele(n); err != nil {
+				return fetched, delivered, fmt.Errorf("DELE %d: %v", n, err)
+			}
+			continue
+		}
+		uids = append(uids, id)
+	}
+	return fetched, uids, nil
+}
+
+func (g *GUI) profileName() string {
+	if name := strings.TrimSpace(g.configFile.Text); name != "" {
+		return name
+	}
+	return "default"
+}
+
+// mailbox returns the Maildir that fetched mail for the current profile
+// goes into.
+func (g *GUI) mailbox() (maildir, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return maildir(filepath.Join(dir, "inbox", "mail", g.profileName())), nil
+}
+
+// fetchPOP3 downloads new mail for the current profile through the SOCKS5
+// proxy into its Maildir.
+func (g *GUI) fetchPOP3(onDone func()) {
+	host := strings.TrimSpace(g.pop3HostEnt.Text)
+	if host == "" {
+		dialog.ShowError(fmt.Errorf("No POP3 server configured"), g.window)
+		return
+	}
+	box, err := g.mailbox()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+		return
+	}
+	cache := make(map[string][]string)
+	locked, err := g.readStore(pop3UIDLFile, pop3UIDLFormat, &cache)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to load UIDL cache: %v", err), g.window)
+		return
+	}
+	if locked {
+		g.showUnlockDialog(storeCheck(pop3UIDLFile, pop3UIDLFormat), func() { g.fetchPOP3(onDone) })
+		return
+	}
+
+	port := strings.TrimSpace(g.pop3PortEnt.Text)
+	security := g.pop3SecuritySelect.Selected
+	username := g.pop3UsernameEnt.Text
+	password := secretBytes(g.pop3PasswordEnt.Text)
+	del := g.pop3DeleteCheck.Checked
+	account := username + "@" + host
+
+	go func() {
+		defer wipeBytes(password)
+
+		updateStatus := func(text string) {
+			fyne.Do(func() {
+				g.statusLabel.SetText(text)
+			})
+		}
+
+		showError := func(err error) {
+			fyne.Do(func() {
+				dialog.ShowError(err, g.window)
+			})
+		}
+
+		updateStatus("Connecting to SOCKS proxy...")
+		dialer, err := proxy.SOCKS5("tcp", "127.0.0.1:"+g.socksPortEnt.Text, nil, proxy.Direct)
+		if err != nil {
+			updateStatus("SOCKS Error: " + err.Error())
+			showError(fmt.Errorf("SOCKS5 error: %v", err))
+			return
+		}
+
+		updateStatus("Connecting to POP3 server...")
+		client, err := dialPOP3(dialer, host, port, security)
+		if err != nil {
+			updateStatus("Connection Error: " + err.Error())
+			showError(fmt.Errorf("Connection failed: %v", err))
+			return
+		}
+
+		updateStatus("Authenticating...")
+		if err := client.auth(username, password); err != nil {
+			client.quit()
+			updateStatus("Auth Error: " + err.Error())
+			showError(fmt.Errorf("Auth failed: %v", err))
+			return
+		}
+
+		updateStatus("Fetching messages...")
+		fetched, uids, err := pop3Fetch(client, box, cache[account], del)
+		if err != nil {
+			// Close without QUIT so the server rolls back any DELE.
+			client.conn.Close()
+			updateStatus("Fetch Error: " + err.Error())
+			showError(fmt.Errorf("Fetch failed: %v", err))
+		} else if err := client.quit(); err != nil {
+			updateStatus("QUIT Error: " + err.Error())
+			showError(fmt.Errorf("QUIT failed: %v", err))
+		}
+
+		fyne.Do(func() {
+			cache[account] = uids
+			if err := g.writeStore(pop3UIDLFile, pop3UIDLFormat, cache); err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to save UIDL cache: %v", err), g.window)
+			}
+			if fetched > 0 || err == nil {
+				g.statusLabel.SetText(fmt.Sprintf("%d new message(s) saved to %s", fetched, string(box)))
+			}
+			onDone()
+		})
+	}()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const profileFormat = "mmg-profile-v1"
+
+func isEncryptedProfile(data []byte) bool {
+	var box sealedBox
+	return yaml.Unmarshal(data, &box) == nil && box.Format == profileFormat
+}
+
+func sealProfile(plaintext, passphrase []byte) ([]byte, error) {
+	box, err := seal(profileFormat, plaintext, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(box)
+}
+
+func openProfile(data, passphrase []byte) ([]byte, error) {
+	var box sealedBox
+	if err := yaml.Unmarshal(data, &box); err != nil {
+		return nil, err
+	}
+	if box.Format != profileFormat {
+		return nil, fmt.Errorf("unsupported profile format %q", box.Format)
+	}
+	return box.open(passphrase)
+}
+
+// writeFileAtomic replaces path with data via a temporary file so a
+// failed write never leaves a half-encrypted profile behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// encryptPlaintextProfiles encrypts every plaintext profile in dir in
+// place and returns the names it migrated.
+func encryptPlaintextProfiles(dir string, passphrase []byte) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+configExtension))
+	if err != nil {
+		return nil, err
+	}
+	var migrated []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return migrated, err
+		}
+		if isEncryptedProfile(data) {
+			continue
+		}
+		var config Config
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return migrated, fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		sealed, err := sealProfile(data, passphrase)
+		zero(data)
+		if err != nil {
+			return migrated, err
+		}
+		if err := writeFileAtomic(path, sealed, 0600); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, strings.TrimSuffix(filepath.Base(path), configExtension))
+	}
+	return migrated, nil
+}
+
+// showUnlockDialog asks for the master passphrase, verifies it with check
+// and then runs onUnlock. Requests made while the prompt is already open
+// are queued behind it so one passphrase unlocks everything.
+func (g *GUI) showUnlockDialog(check func(passphrase []byte) error, onUnlock func()) {
+	g.pendingUnlock = append(g.pendingUnlock, onUnlock)
+	if len(g.pendingUnlock) > 1 {
+		return
+	}
+	passEntry := widget.NewPasswordEntry()
+
+	dialog.ShowForm(
+		"Unlock",
+		"Unlock",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Master passphrase", passEntry),
+		},
+		func(confirmed bool) {
+			pending := g.pendingUnlock
+			g.pendingUnlock = nil
+			if !confirmed {
+				g.statusLabel.SetText("Profile and templates remain locked.")
+				return
+			}
+			passphrase := secretBytes(passEntry.Text)
+			passEntry.SetText("")
+			if err := check(passphrase); err != nil {
+				wipeBytes(passphrase)
+				dialog.ShowError(fmt.Errorf("Failed to unlock: %v", err), g.window)
+				return
+			}
+			g.masterPassphrase = passphrase
+			for _, f := range pending {
+				f()
+			}
+		},
+		g.window,
+	)
+}
+
+// showSetPassphraseDialog asks for a new master passphrase twice and
+// calls onSet once both entries agree.
+func (g *GUI) showSetPassphraseDialog(onSet func()) {
+	passEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+
+	dialog.ShowForm(
+		"Set Master Passphrase",
+		"Set",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Master passphrase", passEntry),
+			widget.NewFormItem("Repeat", confirmEntry),
+		},
+		func(confirmed bool) {
+			defer passEntry.SetText("")
+			defer confirmEntry.SetText("")
+			if !confirmed {
+				g.statusLabel.SetText("Master passphrase not set.")
+				return
+			}
+			if passEntry.Text == "" {
+				dialog.ShowError(fmt.Errorf("Passphrase cannot be empty"), g.window)
+				return
+			}
+			if passEntry.Text != confirmEntry.Text {
+				dialog.ShowError(fmt.Errorf("Passphrases do not match"), g.window)
+				return
+			}
+			g.masterPassphrase = secretBytes(passEntry.Text)
+			onSet()
+		},
+		g.window,
+	)
+}
+
+func (g *GUI) encryptAllProfiles() {
+	if g.masterPassphrase == nil {
+		g.showSetPassphraseDialog(g.encryptAllProfiles)
+		return
+	}
+	configPath, err := os.UserConfigDir()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+		return
+	}
+	migrated, err := encryptPlaintextProfiles(filepath.Join(configPath, configDir), g.masterPassphrase)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to encrypt profiles: %v", err), g.window)
+	}
+	if len(migrated) == 0 {
+		g.statusLabel.SetText("No plaintext profiles to encrypt.")
+		return
+	}
+	g.encryptConfigCheck.SetChecked(true)
+	g.statusLabel.SetText("Encrypted profiles: " + strings.Join(migrated, ", "))
+}
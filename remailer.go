@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+const remailerCapsFile = "remailer-caps.txt"
+
+// remailer is one entry of a remailer capabilities list.
+type remailer struct {
+	Name    string
+	Address string
+	Caps    []string
+}
+
+func (r remailer) has(capability string) bool {
+	for _, c := range r.Caps {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// remailerLine matches the capability lines published by remailer
+// pingers, e.g.
+//
+//	$remailer{"frell"} = "<remailer@frell.example> cpunk pgp latent hash ek";
+var remailerLine = regexp.MustCompile(`^\$remailer\{"([^"]+)"\}\s*=\s*"<([^>]+)>\s*([^"]*)";`)
+
+func parseRemailerCaps(data string) []remailer {
+	var list []remailer
+	for _, line := range strings.Split(data, "\n") {
+		m := remailerLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		list = append(list, remailer{Name: m[1], Address: m[2], Caps: strings.Fields(m[3])})
+	}
+	return list
+}
+
+// loadRemailers collects the capability lines from the imported
+// capabilities list and from the pinger stats files, which carry them too.
+func loadRemailers(dir string) ([]remailer, error) {
+	var list []remailer
+	seen := make(map[string]bool)
+	for _, file := range []string{remailerCapsFile, rlistFile, mlistFile} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range parseRemailerCaps(string(data)) {
+			if !seen[r.Name] {
+				seen[r.Name] = true
+				list = append(list, r)
+			}
+		}
+	}
+	return list, nil
+}
+
+// cpunkRemailers returns the remailers that accept PGP-encrypted Type I
+// messages and have a key in keys.
+func cpunkRemailers(list []remailer, keys openpgp.EntityList) []remailer {
+	var usable []remailer
+	for _, r := range list {
+		if r.has("cpunk") && r.has("pgp") && pgpEntityFor(keys, r.Address) != nil {
+			usable = append(usable, r)
+		}
+	}
+	return usable
+}
+
+// resolveChain looks up a comma separated list of remailer names.
+func resolveChain(spec string, usable []remailer) ([]remailer, error) {
+	byName := make(map[string]remailer)
+	for _, r := range usable {
+		byName[r.Name] = r
+	}
+	var chain []remailer
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		r, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("remailer %q is unknown or has no cpunk/pgp key", name)
+		}
+		chain = append(chain, r)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("chain is empty")
+	}
+	return chain, nil
+}
+
+var latentTime = regexp.MustCompile(`^\+?\d{1,2}:\d{2}r?$`)
+
+// unpastedHeaders are left out of the "##" block handed to the last
+// remailer, because they identify the sender or get set by the remailer.
+var unpastedHeaders = []string{"from", "to", "cc", "bcc", "date", "message-id", "received", "return-path", "sender", "reply-to"}
+
+// wrapCpunkChain wraps the CRLF message raw in nested Type I layers, one
+// PGP-encrypted "Encrypted: PGP" block per remailer, innermost for the
+// last hop. It returns the body to mail to chain[0].
+func wrapCpunkChain(raw string, chain []remailer, keys openpgp.EntityList, latency string) (string, error) {
+	header, body := splitMessage(raw)
+	to := recipientAddresses(header)
+	if len(to) != 1 {
+		return "", fmt.Errorf("a remailer chain needs exactly one recipient")
+	}
+	if latency != "" && !latentTime.MatchString(latency) {
+		return "", fmt.Errorf("invalid Latent-Time %q", latency)
+	}
+
+	if err := checkLine("recipient", to[0]); err != nil {
+		return "", err
+	}
+	for _, r := range chain {
+		if err := checkLine("remailer address "+r.Name, r.Address); err != nil {
+			return "", err
+		}
+	}
+
+	var pseudo strings.Builder
+	pseudo.WriteString("::\r\nAnon-To: " + to[0] + "\r\n")
+	if latency != "" {
+		if !chain[len(chain)-1].has("latent") {
+			return "", fmt.Errorf("remailer %s does not support Latent-Time", chain[len(chain)-1].Name)
+		}
+		pseudo.WriteString("Latent-Time: " + latency + "\r\n")
+	}
+	pseudo.WriteString("\r\n")
+	if pasted := removeFields(headerFields(header), unpastedHeaders...); len(pasted) > 0 {
+		pseudo.WriteString("##\r\n" + strings.Join(pasted, "\r\n") + "\r\n\r\n")
+	}
+	payload := pseudo.String() + body
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		key := pgpEntityFor(keys, chain[i].Address)
+		if key == nil {
+			return "", fmt.Errorf("no key for remailer %s", chain[i].Name)
+		}
+		armored, err := pgpEncrypt([]byte(payload), openpgp.EntityList{key}, nil)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", chain[i].Name, err)
+		}
+		payload = "::\r\nEncrypted: PGP\r\n\r\n" + armored
+		if i > 0 {
+			payload = "::\r\nAnon-To: " + chain[i].Address + "\r\n\r\n" + payload
+		}
+	}
+	return payload, nil
+}
+
+func (g *GUI) importRemailerCaps(onDone func()) {
+	dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if r == nil {
+			return
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to read capabilities: %v", err), g.window)
+			return
+		}
+		list := parseRemailerCaps(string(data))
+		if len(list) == 0 {
+			dialog.ShowError(fmt.Errorf("No $remailer lines found"), g.window)
+			return
+		}
+		dir, err := appConfigDir()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+			return
+		}
+		if err := writeFileAtomic(filepath.Join(dir, remailerCapsFile), data, 0600); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to save capabilities: %v", err), g.window)
+			return
+		}
+		g.statusLabel.SetText(fmt.Sprintf("Imported capabilities of %d remailers.", len(list)))
+		onDone()
+	}, g.window)
+}
+
+func (g *GUI) showRemailerChainDialog() {
+	if g.refuseAttachments("through a remailer chain") {
+		return
+	}
+	dir, err := appConfigDir()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+		return
+	}
+	remailers, err := loadRemailers(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to read remailer capabilities: %v", err), g.window)
+		return
+	}
+	public, _, err := loadPGPKeyrings(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to read keyring: %v", err), g.window)
+		return
+	}
+	usable := cpunkRemailers(remailers, public)
+
+	var lines []string
+	for _, r := range usable {
+		lines = append(lines, fmt.Sprintf("%-12s %s  %s", r.Name, r.Address, strings.Join(r.Caps, " ")))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No cpunk remailers with a PGP key. Import a capabilities list and the remailer keys.")
+	}
+	available := widget.NewLabel(strings.Join(lines, "\n"))
+	available.TextStyle = fyne.TextStyle{Monospace: true}
+
+	chainEntry := widget.NewEntry()
+	chainEntry.SetPlaceHolder("first,second,last")
+	chainEntry.SetText(g.lastRemailerChain)
+	latencyEntry := widget.NewEntry()
+	latencyEntry.SetPlaceHolder("+0:30r (optional)")
+
+	var d dialog.Dialog
+	importButton := widget.NewButton("Import Capabilities", func() {
+		g.importRemailerCaps(func() {
+			d.Hide()
+			g.showRemailerChainDialog()
+		})
+	})
+
+	content := container.NewVBox(
+		widget.NewLabel("Available remailers:"),
+		container.NewHScroll(available),
+		importButton,
+		widget.NewLabel("Chain:"),
+		chainEntry,
+		widget.NewLabel("Latent-Time:"),
+		latencyEntry,
+	)
+
+	d = dialog.NewCustomConfirm(
+		"Remailer Chain",
+		"Send",
+		"Cancel",
+		content,
+		func(confirmed bool) {
+			if !confirmed {
+				g.statusLabel.SetText("Remailer chain cancelled.")
+				return
+			}
+			chain, err := resolveChain(chainEntry.Text, usable)
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			text, err := g.resolveReplyBlocks(g.messageEnt.Text)
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			raw := encodeHeaders(normalizeLineEndings(text))
+			header, _ := splitMessage(raw)
+			headers := parseHeaders(raw)
+			from := extractEmailFromHeaders(headers, "from")
+			if !isValidEmail(from) {
+				dialog.ShowError(fmt.Errorf("Invalid 'From' address"), g.window)
+				return
+			}
+			wrapped, err := wrapCpunkChain(raw, chain, public, strings.TrimSpace(latencyEntry.Text))
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to build chain: %v", err), g.window)
+				return
+			}
+			var fromField string
+			for _, f := range headerFields(header) {
+				if fieldName(f) == "from" {
+					fromField = f
+				}
+			}
+			outer := fromField + "\n" + "To: " + chain[0].Address + "\n\n" + wrapped
+			from, to, message, err := g.prepareMessage(strings.ReplaceAll(outer, "\r\n", "\n"))
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			g.deliver(from, to, message, nil)
+		},
+		g.window,
+	)
+	d.Resize(fyne.NewSize(700, 500))
+	d.Show()
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	mlistFile      = "mlist.txt"
+	rlistFile      = "rlist.txt"
+	mixPubringFile = "pubring.mix"
+)
+
+// remailerStats is one line of an echolot style mlist.txt/rlist.txt.
+type remailerStats struct {
+	Name    string
+	Latency time.Duration
+	Uptime  float64
+	Options string
+}
+
+// parseLatency reads pinger latencies such as ":45", "4:32" or "1:04:32".
+func parseLatency(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("bad latency %q", s)
+	}
+	var d time.Duration
+	units := []time.Duration{time.Second, time.Minute, time.Hour}
+	for i := range parts {
+		p := parts[len(parts)-1-i]
+		if p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("bad latency %q", s)
+		}
+		d += time.Duration(n) * units[i]
+	}
+	return d, nil
+}
+
+// parseRemailerStats reads the stats table of an mlist.txt or rlist.txt:
+// the lines between the dashed ruler and the next blank line.
+func parseRemailerStats(data string) map[string]remailerStats {
+	stats := make(map[string]remailerStats)
+	inTable := false
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "-----") {
+			inTable = true
+			continue
+		}
+		if !inTable {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		f := strings.Fields(line)
+		if len(f) < 5 {
+			continue
+		}
+		latency, err := parseLatency(f[2])
+		if err != nil {
+			continue
+		}
+		uptime, err := strconv.ParseFloat(strings.TrimSuffix(f[4], "%"), 64)
+		if err != nil {
+			continue
+		}
+		s := remailerStats{Name: f[0], Latency: latency, Uptime: uptime}
+		if len(f) > 5 {
+			s.Options = strings.Join(f[5:], " ")
+		}
+		stats[s.Name] = s
+	}
+	return stats
+}
+
+// parseMixPubring reads the key header lines of a Mixmaster pubring.mix,
+// "name address keyid version capabilities", and skips the key blocks.
+func parseMixPubring(data string) []remailer {
+	var list []remailer
+	inKey := false
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "-----Begin Mix Key-----":
+			inKey = true
+		case line == "-----End Mix Key-----":
+			inKey = false
+		case !inKey:
+			f := strings.Fields(line)
+			if len(f) >= 4 && strings.Contains(f[1], "@") {
+				list = append(list, remailer{Name: f[0], Address: f[1]})
+			}
+		}
+	}
+	return list
+}
+
+// remailerEntry is one row of the remailer directory.
+type remailerEntry struct {
+	remailer
+	Stats     remailerStats
+	HasStats  bool
+	HasPGPKey bool
+	HasMixKey bool
+}
+
+func readOptional(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// loadRemailerDirectory merges the imported capabilities, stats and
+// keyrings in dir. Type I stats from rlist.txt win over mlist.txt.
+func loadRemailerDirectory(dir string) ([]remailerEntry, error) {
+	remailers, err := loadRemailers(dir)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*remailerEntry)
+	for _, r := range remailers {
+		byName[r.Name] = &remailerEntry{remailer: r}
+	}
+
+	mix, err := readOptional(filepath.Join(dir, mixPubringFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range parseMixPubring(mix) {
+		e, ok := byName[r.Name]
+		if !ok {
+			e = &remailerEntry{remailer: r}
+			byName[r.Name] = e
+		}
+		e.HasMixKey = true
+	}
+
+	for _, file := range []string{mlistFile, rlistFile} {
+		data, err := readOptional(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		for name, s := range parseRemailerStats(data) {
+			if e, ok := byName[name]; ok {
+				e.Stats = s
+				e.HasStats = true
+			}
+		}
+	}
+
+	public, _, err := loadPGPKeyrings(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []remailerEntry
+	for _, e := range byName {
+		e.HasPGPKey = pgpEntityFor(public, e.Address) != nil
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// randomChain draws hops distinct Type I remailers with a PGP key and at
+// least minUptime percent uptime, using crypto/rand so the choice cannot
+// be predicted.
+func randomChain(entries []remailerEntry, hops int, minUptime float64) ([]string, error) {
+	var pool []string
+	for _, e := range entries {
+		if e.has("cpunk") && e.has("pgp") && e.HasPGPKey && e.HasStats && e.Stats.Uptime >= minUptime {
+			pool = append(pool, e.Name)
+		}
+	}
+	if hops < 1 || hops > len(pool) {
+		return nil, fmt.Errorf("only %d remailers have %.1f%% uptime or better", len(pool), minUptime)
+	}
+	var chain []string
+	for i := 0; i < hops; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(pool))))
+		if err != nil {
+			return nil, err
+		}
+		k := int(n.Int64())
+		chain = append(chain, pool[k])
+		pool = append(pool[:k], pool[k+1:]...)
+	}
+	return chain, nil
+}
+
+func formatLatency(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+func (e remailerEntry) String() string {
+	latency, uptime := "-", "-"
+	if e.HasStats {
+		latency = formatLatency(e.Stats.Latency)
+		uptime = fmt.Sprintf("%.1f%%", e.Stats.Uptime)
+	}
+	var keys []string
+	if e.HasPGPKey {
+		keys = append(keys, "pgp")
+	}
+	if e.HasMixKey {
+		keys = append(keys, "mix")
+	}
+	return fmt.Sprintf("%-12s %-36s %8s %7s  %-7s %s",
+		e.Name, e.Address, latency, uptime, strings.Join(keys, ","), strings.Join(e.Caps, " "))
+}
+
+// importRemailerFile stores a stats file or keyring picked by the user.
+// The file name decides what it is, as pingers publish them under fixed
+// names.
+func (g *GUI) importRemailerFile() {
+	dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if r == nil {
+			return
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to read file: %v", err), g.window)
+			return
+		}
+		dir, err := appConfigDir()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+			return
+		}
+		name := strings.ToLower(r.URI().Name())
+		switch {
+		case strings.HasSuffix(name, ".mix"):
+			if len(parseMixPubring(string(data))) == 0 {
+				dialog.ShowError(fmt.Errorf("No Mixmaster keys found"), g.window)
+				return
+			}
+			err = writeFileAtomic(filepath.Join(dir, mixPubringFile), data, 0600)
+		case strings.Contains(name, "mlist") || strings.Contains(name, "rlist"):
+			if len(parseRemailerStats(string(data))) == 0 {
+				dialog.ShowError(fmt.Errorf("No remailer stats found"), g.window)
+				return
+			}
+			target := mlistFile
+			if strings.Contains(name, "rlist") {
+				target = rlistFile
+			}
+			err = writeFileAtomic(filepath.Join(dir, target), data, 0600)
+		default:
+			var public int
+			public, _, err = importPGPKeys(dir, data)
+			if err == nil {
+				g.statusLabel.SetText(fmt.Sprintf("Imported %d remailer PGP keys.", public))
+			}
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to import %s: %v", r.URI().Name(), err), g.window)
+			return
+		}
+		g.refreshRemailerDirectory()
+	}, g.window)
+}
+
+func (g *GUI) refreshRemailerDirectory() {
+	dir, err := appConfigDir()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to get config directory: %v", err), g.window)
+		return
+	}
+	entries, err := loadRemailerDirectory(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to load remailer directory: %v", err), g.window)
+		return
+	}
+	g.remailers = entries
+	g.selectedRemailer = -1
+	g.remailerList.UnselectAll()
+	g.remailerList.Refresh()
+}
+
+func (g *GUI) buildRemailerTab() *fyne.Container {
+	g.selectedRemailer = -1
+	g.remailerList = widget.NewList(
+		func() int { return len(g.remailers) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("remailer")
+			l.TextStyle = fyne.TextStyle{Monospace: true}
+			return l
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(g.remailers[id].String())
+		},
+	)
+	g.remailerList.OnSelected = func(id widget.ListItemID) {
+		g.selectedRemailer = id
+	}
+
+	header := widget.NewLabel(fmt.Sprintf("%-12s %-36s %8s %7s  %-7s %s",
+		"Name", "Address", "Latency", "Uptime", "Keys", "Capabilities"))
+	header.TextStyle = fyne.TextStyle{Monospace: true}
+
+	hopsEntry := widget.NewEntry()
+	hopsEntry.SetText("3")
+	uptimeEntry := widget.NewEntry()
+	uptimeEntry.SetText("98")
+	chainEntry := widget.NewEntry()
+	chainEntry.SetPlaceHolder("Random chain")
+
+	randomButton := widget.NewButton("Random Chain", func() {
+		hops, err := strconv.Atoi(strings.TrimSpace(hopsEntry.Text))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Hops must be a number"), g.window)
+			return
+		}
+		minUptime, err := strconv.ParseFloat(strings.TrimSpace(uptimeEntry.Text), 64)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Minimum uptime must be a number"), g.window)
+			return
+		}
+		chain, err := randomChain(g.remailers, hops, minUptime)
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		g.lastRemailerChain = strings.Join(chain, ",")
+		chainEntry.SetText(g.lastRemailerChain)
+	})
+
+	copyButton := widget.NewButton("Copy Address", func() {
+		if g.selectedRemailer < 0 || g.selectedRemailer >= len(g.remailers) {
+			dialog.ShowError(fmt.Errorf("No remailer selected"), g.window)
+			return
+		}
+		if err := g.copyToClipboard(g.remailers[g.selectedRemailer].Address); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to copy to clipboard: %v", err), g.window)
+			return
+		}
+		g.statusLabel.SetText("Remailer address copied to clipboard.")
+	})
+
+	controls := container.NewVBox(
+		container.NewHBox(
+			widget.NewButton("Import Stats/Keys", g.importRemailerFile),
+			widget.NewButton("Refresh", g.refreshRemailerDirectory),
+			copyButton,
+		),
+		widget.NewForm(
+			widget.NewFormItem("Hops", hopsEntry),
+			widget.NewFormItem("Min. uptime (%)", uptimeEntry),
+		),
+		container.NewBorder(nil, nil, nil, randomButton, chainEntry),
+	)
+
+	return container.NewBorder(
+		header,
+		controls,
+		nil, nil,
+		container.NewHScroll(g.remailerList),
+	)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// replyText builds the compose text for a reply to the message raw:
+// addressed to its Reply-To or else its sender, with a "Re:" subject,
+// In-Reply-To/References set for threading and the original text quoted
+// below. The From line carries from, the identity the user sends as, or
+// is left empty for them to fill in; it is not guessed from the original
+// To, which may list others or miss a user who was only on Cc.
+func replyText(raw, from string) (string, error) {
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	h := msg.Header
+	recipient := h.Get("Reply-To")
+	if recipient == "" {
+		recipient = h.Get("From")
+	}
+	if recipient == "" {
+		return "", fmt.Errorf("message has neither Reply-To nor From")
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + from + "\n")
+	b.WriteString("To: " + firstAddress(recipient) + "\n")
+	subject := replySubject(decodeHeader(h.Get("Subject")))
+	if !isASCII(subject) {
+		subject = encodeMIMESubject{Subject: subject}.encodeMIMESubject()
+	}
+	b.WriteString("Subject: " + subject + "\n")
+	if id := strings.TrimSpace(h.Get("Message-ID")); id != "" {
+		b.WriteString("In-Reply-To: " + id + "\n")
+		refs := strings.Fields(h.Get("References"))
+		if len(refs) == 0 {
+			refs = strings.Fields(h.Get("In-Reply-To"))
+		}
+		b.WriteString("References: " + strings.Join(append(refs, id), " ") + "\n")
+	}
+	b.WriteString("\n")
+
+	text, err := plainTextBody(h.Get("Content-Type"), h.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return b.String(), nil
+	}
+	author := decodeHeader(h.Get("From"))
+	if date := h.Get("Date"); date != "" {
+		b.WriteString("On " + date + ", " + author + " wrote:\n")
+	} else {
+		b.WriteString(author + " wrote:\n")
+	}
+	b.WriteString(quoteText(text))
+	return b.String(), nil
+}
+
+// replyFrom returns the From value of the selected template, the
+// identity a reply goes out as, or "" when no template sets one.
+func (g *GUI) replyFrom() string {
+	if g.selectedTemplate < 0 || g.selectedTemplate >= len(g.templates) {
+		return ""
+	}
+	for _, line := range strings.Split(g.templates[g.selectedTemplate].Headers, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "from") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// replyStatus is the status line shown once a reply draft is created.
+func replyStatus(from string) string {
+	if from == "" {
+		return "Reply draft created; fill in the From address."
+	}
+	return "Reply draft created."
+}
+
+// quoteText prefixes every line of text with "> ", or just ">" for
+// lines that are already quoted.
+func quoteText(text string) string {
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, ">") || line == "" {
+			b.WriteString(">" + line + "\n")
+		} else {
+			b.WriteString("> " + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// firstAddress returns the first address of an address list header, or
+// the value itself if it does not parse.
+func firstAddress(value string) string {
+	list, err := mail.ParseAddressList(value)
+	if err != nil || len(list) == 0 {
+		return strings.TrimSpace(value)
+	}
+	return list[0].Address
+}
+
+// replySubject prefixes subject with "Re: " unless it already has one.
+func replySubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if len(subject) >= 3 && strings.EqualFold(subject[:3], "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// plainTextMessage renders a CRLF message for reading: the main headers
+// decoded, followed by its first text/plain part with the transfer
+// encoding removed.
+func plainTextMessage(raw string) string {
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return strings.ReplaceAll(raw, "\r\n", "\n")
+	}
+	var b strings.Builder
+	for _, name := range []string{"From", "To", "Cc", "Date", "Subject"} {
+		if v := msg.Header.Get(name); v != "" {
+			b.WriteString(name + ": " + decodeHeader(v) + "\n")
+		}
+	}
+	b.WriteString("\n")
+	text, err := plainTextBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		b.WriteString(fmt.Sprintf("[%v]\n", err))
+		return b.String()
+	}
+	b.WriteString(strings.ReplaceAll(text, "\r\n", "\n"))
+	return b.String()
+}
+
+// plainTextBody finds the first text/plain part of a body with the given
+// Content-Type and decodes it.
+func plainTextBody(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return "", fmt.Errorf("no text/plain part")
+			}
+			if err != nil {
+				return "", err
+			}
+			text, err := plainTextBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err == nil {
+				return text, nil
+			}
+		}
+	}
+	if mediaType != "text/plain" {
+		return "", fmt.Errorf("no text/plain part")
+	}
+	data, err := io.ReadAll(transferDecoder(encoding, body))
+	if err != nil {
+		return "", err
+	}
+	return decodeCharset(params["charset"], data), nil
+}
+
+func transferDecoder(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	}
+	return r
+}
+
+// decodeCharset converts text in charset to UTF-8. Only ASCII, UTF-8 and
+// Latin-1 are converted; anything else is returned as is.
+func decodeCharset(charset string, data []byte) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		runes := make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+// showReplyDialog builds a reply draft from a pasted or opened message
+// and puts it in the compose box.
+func (g *GUI) showReplyDialog() {
+	rawEntry := widget.NewMultiLineEntry()
+	rawEntry.SetPlaceHolder("Paste the raw message, headers included")
+	rawEntry.TextStyle = fyne.TextStyle{Monospace: true}
+	rawEntry.SetMinRowsVisible(12)
+
+	openButton := widget.NewButton("Open .eml...", func() {
+		open := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			if r == nil {
+				return
+			}
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to read message: %v", err), g.window)
+				return
+			}
+			rawEntry.SetText(string(data))
+		}, g.window)
+		open.SetFilter(storage.NewExtensionFileFilter([]string{".eml", ".txt"}))
+		open.Show()
+	})
+
+	dialog.ShowCustomConfirm(
+		"Reply",
+		"Create Reply",
+		"Cancel",
+		container.NewBorder(nil, openButton, nil, nil, rawEntry),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			raw := normalizeLineEndings(strings.ReplaceAll(rawEntry.Text, "\r\n", "\n"))
+			from := g.replyFrom()
+			text, err := replyText(raw, from)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to build reply: %v", err), g.window)
+				return
+			}
+			g.messageEnt.SetText(text)
+			g.statusLabel.SetText(replyStatus(from))
+		},
+		g.window,
+	)
+}
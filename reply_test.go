@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplyTextFrom(t *testing.T) {
+	const original = "From: Alice <alice@example.org>\r\n" +
+		"To: bob@example.org, me@example.net\r\n" +
+		"Cc: carol@example.org\r\n" +
+		"Subject: plans\r\n" +
+		"Message-ID: <1@example.org>\r\n" +
+		"\r\n" +
+		"See you.\r\n"
+	tests := []struct {
+		name, from, want string
+	}{
+		{"template identity", "me@example.net", "From: me@example.net\n"},
+		{"no identity", "", "From: \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := replyText(original, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(text, tt.want) {
+				t.Errorf("reply starts %q, want %q", strings.SplitN(text, "\n", 2)[0], tt.want)
+			}
+			if strings.Contains(text, "bob@example.org") {
+				t.Error("reply is sent as the original's first To recipient")
+			}
+			for _, line := range []string{"To: alice@example.org\n", "Subject: Re: plans\n", "In-Reply-To: <1@example.org>\n", "> See you.\n"} {
+				if !strings.Contains(text, line) {
+					t.Errorf("reply lacks %q:\n%s", line, text)
+				}
+			}
+		})
+	}
+}
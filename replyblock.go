@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	replyBlockFile   = "replyblocks.json"
+	replyBlockFormat = "mmg-replyblocks-v1"
+)
+
+// replyBlock is a stored reply block or nym reply path, referenced from
+// templates and nym requests as {{replyblock:Name}}.
+type replyBlock struct {
+	Name     string `json:"name"`
+	Identity string `json:"identity"`
+	Block    string `json:"block"`
+}
+
+var replyBlockRef = regexp.MustCompile(`\{\{replyblock:([^{}\s]+)\}\}`)
+
+var replyBlockName = regexp.MustCompile(`^[^{}\s]+$`)
+
+func replyBlockReference(name string) string {
+	return "{{replyblock:" + name + "}}"
+}
+
+// resolveReplyBlocks replaces every {{replyblock:name}} in text with the
+// stored block of that name.
+func resolveReplyBlocks(text string, blocks []replyBlock) (string, error) {
+	var missing []string
+	out := replyBlockRef.ReplaceAllStringFunc(text, func(ref string) string {
+		name := replyBlockRef.FindStringSubmatch(ref)[1]
+		for _, b := range blocks {
+			if b.Name == name {
+				return strings.TrimRight(b.Block, "\r\n")
+			}
+		}
+		missing = append(missing, name)
+		return ref
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unknown reply block %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// resolveReplyBlocks resolves the placeholders in text against the
+// reply block store.
+func (g *GUI) resolveReplyBlocks(text string) (string, error) {
+	if !replyBlockRef.MatchString(text) {
+		return text, nil
+	}
+	if !g.replyBlocksLoaded {
+		return "", fmt.Errorf("Reply block store is locked")
+	}
+	return resolveReplyBlocks(text, g.replyBlocks)
+}
+
+func (g *GUI) loadReplyBlocks() {
+	var blocks []replyBlock
+	locked, err := g.readStore(replyBlockFile, replyBlockFormat, &blocks)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Failed to load reply blocks: %v", err), g.window)
+		return
+	}
+	if locked {
+		g.showUnlockDialog(storeCheck(replyBlockFile, replyBlockFormat), g.loadReplyBlocks)
+		return
+	}
+	g.replyBlocks = blocks
+	g.replyBlocksLoaded = true
+	g.replyBlockList.Refresh()
+}
+
+// saveReplyBlocks writes the store. Reply blocks are never kept in
+// plaintext, so callers make sure a master passphrase is set first.
+func (g *GUI) saveReplyBlocks() error {
+	if !g.replyBlocksLoaded {
+		return fmt.Errorf("Reply block store is locked")
+	}
+	if g.masterPassphrase == nil {
+		return fmt.Errorf("No master passphrase set")
+	}
+	return g.writeStore(replyBlockFile, replyBlockFormat, g.replyBlocks)
+}
+
+func (g *GUI) buildReplyBlockTab() *fyne.Container {
+	g.selectedReplyBlock = -1
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Reference name")
+	identityEntry := widget.NewEntry()
+	identityEntry.SetPlaceHolder("Identity (nym or address)")
+	blockEntry := widget.NewMultiLineEntry()
+	blockEntry.SetPlaceHolder("Reply block")
+	blockEntry.TextStyle = fyne.TextStyle{Monospace: true}
+
+	fill := func(b replyBlock) {
+		nameEntry.SetText(b.Name)
+		identityEntry.SetText(b.Identity)
+		blockEntry.SetText(b.Block)
+	}
+
+	g.replyBlockList = widget.NewList(
+		func() int { return len(g.replyBlocks) },
+		func() fyne.CanvasObject { return widget.NewLabel("reply block") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			b := g.replyBlocks[id]
+			o.(*widget.Label).SetText(b.Identity + " / " + b.Name)
+		},
+	)
+	g.replyBlockList.OnSelected = func(id widget.ListItemID) {
+		g.selectedReplyBlock = id
+		fill(g.replyBlocks[id])
+	}
+
+	var save func()
+	save = func() {
+		if g.masterPassphrase == nil {
+			g.showSetPassphraseDialog(save)
+			return
+		}
+		b := replyBlock{
+			Name:     strings.TrimSpace(nameEntry.Text),
+			Identity: strings.TrimSpace(identityEntry.Text),
+			Block:    blockEntry.Text,
+		}
+		if !replyBlockName.MatchString(b.Name) {
+			dialog.ShowError(fmt.Errorf("Name must be non-empty and contain no spaces or braces"), g.window)
+			return
+		}
+		if strings.TrimSpace(b.Block) == "" {
+			dialog.ShowError(fmt.Errorf("Reply block is empty"), g.window)
+			return
+		}
+		found := false
+		for i := range g.replyBlocks {
+			if g.replyBlocks[i].Name == b.Name {
+				g.replyBlocks[i] = b
+				found = true
+			}
+		}
+		if !found {
+			g.replyBlocks = append(g.replyBlocks, b)
+		}
+		if err := g.saveReplyBlocks(); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to save reply blocks: %v", err), g.window)
+			return
+		}
+		g.replyBlockList.Refresh()
+		g.statusLabel.SetText("Reply block saved as " + replyBlockReference(b.Name))
+	}
+
+	controls := container.NewHBox(
+		widget.NewButton("New", func() {
+			g.replyBlockList.UnselectAll()
+			g.selectedReplyBlock = -1
+			fill(replyBlock{})
+		}),
+		widget.NewButton("Save", save),
+		widget.NewButton("Delete", func() {
+			if g.selectedReplyBlock < 0 || g.selectedReplyBlock >= len(g.replyBlocks) {
+				dialog.ShowError(fmt.Errorf("No reply block selected"), g.window)
+				return
+			}
+			g.replyBlocks = append(g.replyBlocks[:g.selectedReplyBlock], g.replyBlocks[g.selectedReplyBlock+1:]...)
+			if err := g.saveReplyBlocks(); err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to save reply blocks: %v", err), g.window)
+				return
+			}
+			g.selectedReplyBlock = -1
+			g.replyBlockList.UnselectAll()
+			g.replyBlockList.Refresh()
+			fill(replyBlock{})
+		}),
+		widget.NewButton("Copy Reference", func() {
+			name := strings.TrimSpace(nameEntry.Text)
+			if !replyBlockName.MatchString(name) {
+				dialog.ShowError(fmt.Errorf("No reply block selected"), g.window)
+				return
+			}
+			if err := g.copyToClipboard(replyBlockReference(name)); err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to copy to clipboard: %v", err), g.window)
+				return
+			}
+			g.statusLabel.SetText("Reference " + replyBlockReference(name) + " copied to clipboard.")
+		}),
+	)
+
+	g.loadReplyBlocks()
+
+	return container.NewBorder(
+		nil, nil,
+		container.NewBorder(widget.NewLabel("Reply Blocks"), nil, nil, nil, g.replyBlockList),
+		nil,
+		container.NewBorder(
+			container.NewVBox(
+				widget.NewForm(
+					widget.NewFormItem("Name", nameEntry),
+					widget.NewFormItem("Identity", identityEntry),
+				),
+				controls,
+			),
+			nil, nil, nil,
+			container.NewScroll(blockEntry),
+		),
+	)
+}
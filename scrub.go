@@ -0,0 +1,329 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// scrubMetadata removes identifying metadata from an attachment. It
+// returns the cleaned data and a line for each kind of metadata removed.
+// Plain text passes through unchanged; JPEG, PNG, PDF and OOXML files are
+// cleaned; any other file, or one of those that cannot be parsed, is
+// reported as an error so it is not sent by accident.
+func scrubMetadata(data []byte) ([]byte, []string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return scrubJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return scrubPNG(data)
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return scrubPDF(data)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return scrubOOXML(data)
+	case utf8.Valid(data) && !bytes.ContainsRune(data, 0):
+		return data, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported file type, metadata cannot be checked")
+}
+
+// jpegSegment names the JPEG marker segments that are dropped. APP0
+// (JFIF) and APP14 (Adobe colour transform) are needed to decode the
+// image and are kept.
+func jpegSegment(marker byte) string {
+	switch {
+	case marker == 0xe1:
+		return "EXIF/XMP (APP1)"
+	case marker == 0xe2:
+		return "ICC profile (APP2)"
+	case marker == 0xed:
+		return "IPTC/Photoshop (APP13)"
+	case marker > 0xe0 && marker <= 0xef && marker != 0xee:
+		return fmt.Sprintf("APP%d", marker-0xe0)
+	case marker == 0xfe:
+		return "comment"
+	}
+	return ""
+}
+
+func scrubJPEG(data []byte) ([]byte, []string, error) {
+	out := []byte{0xff, 0xd8}
+	var report []string
+	pos := 2
+	for {
+		for pos < len(data) && data[pos] == 0xff && pos+1 < len(data) && data[pos+1] == 0xff {
+			pos++
+		}
+		if pos+4 > len(data) || data[pos] != 0xff {
+			return nil, nil, fmt.Errorf("truncated JPEG")
+		}
+		marker := data[pos+1]
+		if marker == 0xda {
+			// Start of scan: the entropy-coded image data follows.
+			out = append(out, data[pos:]...)
+			return out, report, nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, nil, fmt.Errorf("malformed JPEG segment")
+		}
+		if name := jpegSegment(marker); name != "" {
+			report = append(report, fmt.Sprintf("JPEG %s, %d bytes", name, length-2))
+		} else {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the ancillary PNG chunks that carry text, EXIF,
+// timestamps or an ICC profile name.
+var pngMetadataChunks = []string{"tEXt", "zTXt", "iTXt", "eXIf", "tIME", "iCCP"}
+
+func scrubPNG(data []byte) ([]byte, []string, error) {
+	out := append([]byte(nil), pngSignature...)
+	var report []string
+	pos := len(pngSignature)
+	for {
+		if pos+12 > len(data) {
+			return nil, nil, fmt.Errorf("truncated PNG")
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, nil, fmt.Errorf("malformed PNG chunk")
+		}
+		if validChoice(pngMetadataChunks, kind) {
+			report = append(report, fmt.Sprintf("PNG %s chunk, %d bytes", kind, length))
+		} else {
+			out = append(out, data[pos:end]...)
+		}
+		if kind == "IEND" {
+			return out, report, nil
+		}
+		pos = end
+	}
+}
+
+var (
+	pdfInfoRef     = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	pdfMetadataRef = regexp.MustCompile(`/Metadata\s+\d+\s+\d+\s+R`)
+	pdfXMPStream   = regexp.MustCompile(`(?s)/Type\s*/Metadata\b.*?stream\r?\n(.*?)endstream`)
+)
+
+// blank overwrites data[start:end] with spaces. PDF cross-reference
+// tables hold byte offsets, so cleaning in place keeps the file valid
+// without rewriting them.
+func blank(data []byte, start, end int) {
+	for i := start; i < end; i++ {
+		data[i] = ' '
+	}
+}
+
+// scrubPDF empties every revision of the document information
+// dictionary and drops the XMP metadata streams. Files with compressed
+// object streams are refused, since metadata inside them cannot be seen
+// without rewriting the file.
+func scrubPDF(data []byte) ([]byte, []string, error) {
+	if bytes.Contains(data, []byte("/ObjStm")) {
+		return nil, nil, fmt.Errorf("PDF uses compressed object streams, metadata cannot be removed")
+	}
+	out := append([]byte(nil), data...)
+	var report []string
+
+	seen := map[string]bool{}
+	for _, m := range pdfInfoRef.FindAllSubmatch(out, -1) {
+		ref := string(m[1]) + " " + string(m[2])
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		obj := regexp.MustCompile(`(?s)(?:^|\s)` + string(m[1]) + `\s+` + string(m[2]) + `\s+obj\b(.*?)endobj`)
+		found := false
+		for _, loc := range obj.FindAllSubmatchIndex(out, -1) {
+			body := out[loc[2]:loc[3]]
+			open, close := bytes.Index(body, []byte("<<")), bytes.LastIndex(body, []byte(">>"))
+			if open < 0 || close <= open {
+				continue
+			}
+			blank(out, loc[2]+open+2, loc[2]+close)
+			found = true
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("PDF document info object %s not found", ref)
+		}
+		report = append(report, "PDF document info (author, title, producer, dates)")
+	}
+
+	refs := pdfMetadataRef.FindAllIndex(out, -1)
+	for _, loc := range refs {
+		blank(out, loc[0], loc[1])
+	}
+	streams := pdfXMPStream.FindAllSubmatchIndex(out, -1)
+	for _, loc := range streams {
+		blank(out, loc[2], loc[3])
+	}
+	if len(refs) > 0 || len(streams) > 0 {
+		report = append(report, fmt.Sprintf("PDF XMP metadata, %d stream(s)", len(streams)))
+	}
+	return out, report, nil
+}
+
+// ooxmlProperties replaces the OOXML document property parts with empty
+// documents of the same schema, so the package stays valid.
+var ooxmlProperties = map[string]string{
+	"docProps/core.xml":   `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\r\n" + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"/>`,
+	"docProps/app.xml":    `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\r\n" + `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties"/>`,
+	"docProps/custom.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\r\n" + `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties"/>`,
+}
+
+// zipEpoch is the MS-DOS date of 1980-01-01, written as every entry's
+// modification time in place of the local time the file was saved at.
+const zipEpoch = 1<<5 | 1
+
+var (
+	// wordAuthorAttr matches the names Word writes on comments, tracked
+	// changes and in the people part.
+	wordAuthorAttr = regexp.MustCompile(`\b((?:w|w15):(?:author|initials|userId))="[^"]*"`)
+	// excelAuthor matches the comment authors of Excel's legacy comments.
+	excelAuthor = regexp.MustCompile(`<author>[^<]*</author>`)
+	// ooxmlOtherAuthors are parts that name people in a form scrubOOXML
+	// does not rewrite; they are listed in the report instead.
+	ooxmlOtherAuthors = regexp.MustCompile(`^(ppt/commentAuthors\.xml|ppt/authors\.xml|xl/persons/.*|xl/threadedComments/.*)$`)
+)
+
+// ooxmlMedia reports whether an entry is an embedded image or the
+// document thumbnail, which carry metadata of their own.
+func ooxmlMedia(name string) bool {
+	return strings.HasPrefix(name, "docProps/thumbnail.") || strings.Contains(name, "/media/")
+}
+
+// scrubOOXML empties the document properties of a Word, Excel or
+// PowerPoint file, cleans the embedded JPEG and PNG images and the
+// thumbnail, blanks comment and revision authors in Word and Excel
+// comments, and resets the timestamps of the zip entries. Other images
+// and author lists it cannot rewrite are named in the report as not
+// cleaned.
+func scrubOOXML(data []byte) ([]byte, []string, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed zip archive: %v", err)
+	}
+	isOOXML := false
+	for _, f := range r.File {
+		if f.Name == "[Content_Types].xml" {
+			isOOXML = true
+		}
+	}
+	if !isOOXML {
+		return nil, nil, fmt.Errorf("zip archive is not an Office document, metadata cannot be checked")
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	var report []string
+	for _, f := range r.File {
+		fh := f.FileHeader
+		fh.Modified = time.Time{}
+		fh.Comment = ""
+		fh.Extra = nil
+		fh.ModifiedDate, fh.ModifiedTime = zipEpoch, 0
+
+		cleaned, notes, err := scrubOOXMLPart(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		report = append(report, notes...)
+		if cleaned != nil {
+			fh.Method = zip.Deflate
+			part, err := w.CreateHeader(&fh)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, err := part.Write(cleaned); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return nil, nil, err
+		}
+		part, err := w.CreateRaw(&fh)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := io.Copy(part, raw); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	report = append(report, "zip entry timestamps")
+	return buf.Bytes(), report, nil
+}
+
+// scrubOOXMLPart cleans one entry of an Office package. It returns the
+// new content, or nil when the entry is copied as it is, and the report
+// lines for it.
+func scrubOOXMLPart(f *zip.File) ([]byte, []string, error) {
+	if props, ok := ooxmlProperties[f.Name]; ok {
+		return []byte(props), []string{"Office " + strings.TrimPrefix(f.Name, "docProps/") + " properties"}, nil
+	}
+	if ooxmlOtherAuthors.MatchString(f.Name) {
+		return nil, []string{"Office " + f.Name + ": author names NOT removed"}, nil
+	}
+	media := ooxmlMedia(f.Name)
+	wordXML := strings.HasPrefix(f.Name, "word/") && strings.HasSuffix(f.Name, ".xml")
+	excelComments := strings.HasPrefix(f.Name, "xl/comments") && strings.HasSuffix(f.Name, ".xml")
+	if !media && !wordXML && !excelComments {
+		return nil, nil, nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cleaned []byte
+	var removed []string
+	switch {
+	case media:
+		if !bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}) && !bytes.HasPrefix(data, pngSignature) {
+			return nil, []string{"Office " + f.Name + ": image metadata NOT checked"}, nil
+		}
+		if cleaned, removed, err = scrubMetadata(data); err != nil {
+			return nil, nil, err
+		}
+	case wordXML:
+		cleaned = wordAuthorAttr.ReplaceAll(data, []byte(`$1=""`))
+		removed = []string{"comment and revision authors"}
+	default:
+		cleaned = excelAuthor.ReplaceAll(data, []byte(`<author></author>`))
+		removed = []string{"comment authors"}
+	}
+	if bytes.Equal(cleaned, data) {
+		return nil, nil, nil
+	}
+	report := make([]string, len(removed))
+	for i, r := range removed {
+		report[i] = "Office " + f.Name + ": " + r
+	}
+	return cleaned, report, nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+// jpegSeg builds a JPEG marker segment with the given payload.
+func jpegSeg(marker byte, payload string) []byte {
+	seg := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// testJPEG is an image with JFIF, EXIF, ICC and comment segments before
+// the quantisation table and the scan. A fill byte precedes the COM
+// marker. The entropy-coded data is not a real image; scrubJPEG copies it
+// as it is.
+func testJPEG() []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0xff, 0xd8})
+	b.Write(jpegSeg(0xe0, "JFIF\x00\x01\x01"))
+	b.Write(jpegSeg(0xe1, "Exif\x00\x00camera serial 1234"))
+	b.Write(jpegSeg(0xe2, "ICC_PROFILE\x00"))
+	b.WriteByte(0xff)
+	b.Write(jpegSeg(0xfe, "taken by alice"))
+	b.Write(jpegSeg(0xdb, "\x00quant"))
+	b.Write(jpegSeg(0xda, "\x01scan"))
+	b.Write([]byte("\x12\x34\xff\x00\x56\xff\xd9"))
+	return b.Bytes()
+}
+
+func TestScrubJPEG(t *testing.T) {
+	out, report, err := scrubMetadata(testJPEG())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	want.Write([]byte{0xff, 0xd8})
+	want.Write(jpegSeg(0xe0, "JFIF\x00\x01\x01"))
+	want.Write(jpegSeg(0xdb, "\x00quant"))
+	want.Write(jpegSeg(0xda, "\x01scan"))
+	want.Write([]byte("\x12\x34\xff\x00\x56\xff\xd9"))
+	if !bytes.Equal(out, want.Bytes()) {
+		t.Errorf("scrubbed JPEG\n%q\nwant\n%q", out, want.Bytes())
+	}
+	wantReport := []string{"JPEG EXIF/XMP (APP1), 24 bytes", "JPEG ICC profile (APP2), 12 bytes", "JPEG comment, 14 bytes"}
+	if strings.Join(report, "|") != strings.Join(wantReport, "|") {
+		t.Errorf("report %q, want %q", report, wantReport)
+	}
+}
+
+func TestScrubJPEGMalformed(t *testing.T) {
+	good := testJPEG()
+	badLength := append([]byte(nil), good...)
+	binary.BigEndian.PutUint16(badLength[4:], 0xfff0)
+	tests := map[string][]byte{
+		"truncated before scan": good[:40],
+		"segment past the end":  badLength,
+		"zero length":           append([]byte{0xff, 0xd8, 0xff, 0xe1, 0, 0}, good[2:]...),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := scrubJPEG(data); err == nil {
+				t.Error("scrubJPEG accepted a malformed file")
+			}
+		})
+	}
+}
+
+// pngChunk builds a PNG chunk. scrubPNG does not check CRCs, so a fixed
+// one is written.
+func pngChunk(kind, payload string) []byte {
+	c := make([]byte, 4, 12+len(payload))
+	binary.BigEndian.PutUint32(c, uint32(len(payload)))
+	c = append(c, kind...)
+	c = append(c, payload...)
+	return append(c, 0xde, 0xad, 0xbe, 0xef)
+}
+
+func testPNG(chunks ...[]byte) []byte {
+	return bytes.Join(append([][]byte{pngSignature}, chunks...), nil)
+}
+
+func TestScrubPNG(t *testing.T) {
+	ihdr := pngChunk("IHDR", "0123456789abc")
+	idat := pngChunk("IDAT", "pixels")
+	iend := pngChunk("IEND", "")
+	in := testPNG(ihdr,
+		pngChunk("tEXt", "Author\x00alice"),
+		pngChunk("iCCP", "name\x00\x00x"),
+		idat,
+		pngChunk("tIME", "\x07\xe8\x01\x02\x03\x04\x05"),
+		iend,
+		[]byte("trailing garbage"))
+	out, report, err := scrubMetadata(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testPNG(ihdr, idat, iend); !bytes.Equal(out, want) {
+		t.Errorf("scrubbed PNG\n%q\nwant\n%q", out, want)
+	}
+	wantReport := []string{"PNG tEXt chunk, 12 bytes", "PNG iCCP chunk, 7 bytes", "PNG tIME chunk, 7 bytes"}
+	if strings.Join(report, "|") != strings.Join(wantReport, "|") {
+		t.Errorf("report %q, want %q", report, wantReport)
+	}
+
+	for name, data := range map[string][]byte{
+		"no IEND":            testPNG(ihdr, idat),
+		"chunk past the end": testPNG(ihdr, pngChunk("IDAT", "pixels")[:12]),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := scrubPNG(data); err == nil {
+				t.Error("scrubPNG accepted a malformed file")
+			}
+		})
+	}
+}
+
+const testPDF = "%PDF-1.4\n" +
+	"1 0 obj\n<< /Type /Catalog /Pages 2 0 R /Metadata 4 0 R >>\nendobj\n" +
+	"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n" +
+	"3 0 obj\n<< /Author (Alice Example) /Producer (Writer 7.1) /CreationDate (D:20240102) >>\nendobj\n" +
+	"4 0 obj\n<< /Type /Metadata /Subtype /XML /Length 44 >>\nstream\n" +
+	"<x:xmpmeta><dc:creator>Alice</dc:creator>\n" +
+	"endstream\nendobj\n" +
+	"xref\n0 5\n" +
+	"trailer\n<< /Size 5 /Root 1 0 R /Info 3 0 R >>\nstartxref\n0\n%%EOF\n"
+
+func TestScrubPDF(t *testing.T) {
+	out, report, err := scrubMetadata([]byte(testPDF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(testPDF) {
+		t.Errorf("scrubbed PDF is %d bytes, want %d so xref offsets stay valid", len(out), len(testPDF))
+	}
+	for _, gone := range []string{"Alice", "Writer 7.1", "D:20240102", "xmpmeta", "/Metadata 4 0 R"} {
+		if bytes.Contains(out, []byte(gone)) {
+			t.Errorf("scrubbed PDF still contains %q", gone)
+		}
+	}
+	for _, kept := range []string{"/Type /Catalog /Pages 2 0 R", "/Type /Pages /Kids [] /Count 0", "3 0 obj\n<<", ">>\nendobj", "/Info 3 0 R", "stream\n", "endstream"} {
+		if !bytes.Contains(out, []byte(kept)) {
+			t.Errorf("scrubbed PDF lost %q", kept)
+		}
+	}
+	wantReport := []string{"PDF document info (author, title, producer, dates)", "PDF XMP metadata, 1 stream(s)"}
+	if strings.Join(report, "|") != strings.Join(wantReport, "|") {
+		t.Errorf("report %q, want %q", report, wantReport)
+	}
+}
+
+func TestScrubPDFRefused(t *testing.T) {
+	tests := map[string]string{
+		"object streams":   strings.Replace(testPDF, "/Type /Pages", "/Type /ObjStm", 1),
+		"missing info obj": strings.Replace(testPDF, "/Info 3 0 R", "/Info 9 0 R", 1),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := scrubPDF([]byte(data)); err == nil {
+				t.Error("scrubPDF accepted a file it cannot clean")
+			}
+		})
+	}
+}
+
+// testOOXML builds a minimal Word package whose parts carry metadata in
+// every place scrubOOXML looks.
+func testOOXML(t *testing.T) []byte {
+	t.Helper()
+	parts := []struct{ name, data string }{
+		{"[Content_Types].xml", `<Types/>`},
+		{"docProps/core.xml", `<cp:coreProperties><dc:creator>Alice</dc:creator></cp:coreProperties>`},
+		{"docProps/thumbnail.jpeg", string(testJPEG())},
+		{"word/document.xml", `<w:body><w:ins w:id="1" w:author="Alice" w:date="2024-01-02T00:00:00Z"><w:r/></w:ins></w:body>`},
+		{"word/comments.xml", `<w:comments><w:comment w:id="0" w:author="Alice" w:initials="AE"/></w:comments>`},
+		{"word/media/image1.png", string(testPNG(pngChunk("IHDR", "0123456789abc"), pngChunk("tEXt", "Author\x00Alice"), pngChunk("IEND", "")))},
+		{"word/media/image2.gif", "GIF89a"},
+		{"word/styles.xml", `<w:styles/>`},
+		{"ppt/commentAuthors.xml", `<p:cmAuthorLst><p:cmAuthor name="Alice"/></p:cmAuthorLst>`},
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, p := range parts {
+		f, err := w.Create(p.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(p.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScrubOOXML(t *testing.T) {
+	out, report, err := scrubMetadata(testOOXML(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range r.File {
+		if f.ModifiedDate != zipEpoch || f.ModifiedTime != 0 {
+			t.Errorf("%s keeps its timestamp", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		parts[f.Name] = data
+	}
+	if len(parts) != 9 {
+		t.Errorf("scrubbed package has %d parts, want 9", len(parts))
+	}
+	for _, name := range []string{"docProps/core.xml", "docProps/thumbnail.jpeg", "word/document.xml", "word/comments.xml", "word/media/image1.png"} {
+		if bytes.Contains(parts[name], []byte("Alice")) || bytes.Contains(parts[name], []byte("camera serial")) {
+			t.Errorf("%s still names the author: %q", name, parts[name])
+		}
+	}
+	if string(parts["word/comments.xml"]) != `<w:comments><w:comment w:id="0" w:author="" w:initials=""/></w:comments>` {
+		t.Errorf("comments.xml = %q", parts["word/comments.xml"])
+	}
+	if !bytes.Contains(parts["word/document.xml"], []byte(`w:date="2024-01-02T00:00:00Z"`)) {
+		t.Errorf("document.xml lost more than the author: %q", parts["word/document.xml"])
+	}
+	for _, name := range []string{"word/styles.xml", "word/media/image2.gif", "ppt/commentAuthors.xml"} {
+		if len(parts[name]) == 0 {
+			t.Errorf("%s was not copied", name)
+		}
+	}
+
+	wantReport := []string{
+		"Office core.xml properties",
+		"Office docProps/thumbnail.jpeg: JPEG EXIF/XMP (APP1), 24 bytes",
+		"Office docProps/thumbnail.jpeg: JPEG ICC profile (APP2), 12 bytes",
+		"Office docProps/thumbnail.jpeg: JPEG comment, 14 bytes",
+		"Office word/document.xml: comment and revision authors",
+		"Office word/comments.xml: comment and revision authors",
+		"Office word/media/image1.png: PNG tEXt chunk, 12 bytes",
+		"Office word/media/image2.gif: image metadata NOT checked",
+		"Office ppt/commentAuthors.xml: author names NOT removed",
+		"zip entry timestamps",
+	}
+	if strings.Join(report, "\n") != strings.Join(wantReport, "\n") {
+		t.Errorf("report:\n%s\nwant:\n%s", strings.Join(report, "\n"), strings.Join(wantReport, "\n"))
+	}
+}
+
+func TestScrubOOXMLNotOffice(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	if _, err := w.Create("notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, _, err := scrubMetadata(buf.Bytes()); err == nil {
+		t.Error("scrubMetadata accepted a plain zip archive")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/smtp"
+)
+
+// secretBytes copies a password or key into a buffer of its own that is
+// kept out of swap where the platform allows. Release it with wipeBytes.
+func secretBytes(s string) []byte {
+	b := []byte(s)
+	lockMemory(b)
+	return b
+}
+
+// wipeBytes zeroes and unlocks a buffer obtained from secretBytes.
+func wipeBytes(b []byte) {
+	zero(b)
+	unlockMemory(b)
+}
+
+// plainAuth is smtp.PlainAuth with the password held in a byte buffer,
+// so the caller can wipe it once the session is authenticated.
+type plainAuth struct {
+	username string
+	password []byte
+	host     string
+	resp     []byte
+}
+
+func newPlainAuth(username string, password []byte, host string) *plainAuth {
+	return &plainAuth{username: username, password: password, host: host}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+func (a *plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Same guard as smtp.PlainAuth: never send the password in the clear.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	a.resp = make([]byte, 0, len(a.username)+len(a.password)+2)
+	a.resp = append(a.resp, 0)
+	a.resp = append(a.resp, a.username...)
+	a.resp = append(a.resp, 0)
+	a.resp = append(a.resp, a.password...)
+	lockMemory(a.resp)
+	return "PLAIN", a.resp, nil
+}
+
+func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("unexpected server challenge")
+	}
+	return nil, nil
+}
+
+// wipe zeroes the password and the PLAIN response built from it.
+func (a *plainAuth) wipe() {
+	wipeBytes(a.password)
+	wipeBytes(a.resp)
+}
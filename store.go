@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// readStore loads the JSON file name from the config directory into v,
+// decrypting it when it was sealed under format. locked is true when the
+// file is encrypted and no master passphrase has been entered yet; v is
+// left untouched then. A missing file is not an error.
+func (g *GUI) readStore(name, format string, v interface{}) (locked bool, err error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if isSealedJSON(format, data) {
+		if g.masterPassphrase == nil {
+			return true, nil
+		}
+		data, err = openJSON(format, data, g.masterPassphrase)
+		if err != nil {
+			return false, err
+		}
+		defer zero(data)
+	}
+	return false, json.Unmarshal(data, v)
+}
+
+// writeStore saves v as the JSON file name in the config directory. The
+// file is sealed under format whenever a master passphrase is set, since
+// everything kept this way holds addresses or reply paths.
+func (g *GUI) writeStore(name, format string, v interface{}) error {
+	dir, err := appConfigDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if g.masterPassphrase != nil {
+		sealed, err := sealJSON(format, data, g.masterPassphrase)
+		zero(data)
+		if err != nil {
+			return err
+		}
+		data = sealed
+	}
+	return writeFileAtomic(filepath.Join(dir, name), data, 0600)
+}
+
+// storeCheck returns a passphrase check for showUnlockDialog that tries to
+// open the sealed file name.
+func storeCheck(name, format string) func(passphrase []byte) error {
+	return func(passphrase []byte) error {
+		dir, err := appConfigDir()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		plaintext, err := openJSON(format, data, passphrase)
+		zero(plaintext)
+		return err
+	}
+}
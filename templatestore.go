@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+const templatesFormat = "mmg-templates-v1"
+
+func isEncryptedTemplates(data []byte) bool {
+	return isSealedJSON(templatesFormat, data)
+}
+
+func sealTemplates(plaintext, passphrase []byte) ([]byte, error) {
+	return sealJSON(templatesFormat, plaintext, passphrase)
+}
+
+func openTemplates(data, passphrase []byte) ([]byte, error) {
+	return openJSON(templatesFormat, data, passphrase)
+}
+
+// setTemplateEncryption switches the template store between plaintext and
+// encrypted form and rewrites it immediately.
+func (g *GUI) setTemplateEncryption(encrypt bool) {
+	if encrypt == g.encryptTemplates {
+		return
+	}
+	if g.templatesLocked {
+		g.encryptTemplatesCheck.SetChecked(g.encryptTemplates)
+		dialog.ShowError(fmt.Errorf("Template store is locked"), g.window)
+		return
+	}
+	if encrypt && g.masterPassphrase == nil {
+		g.encryptTemplatesCheck.SetChecked(false)
+		g.showSetPassphraseDialog(func() {
+			g.encryptTemplatesCheck.SetChecked(true)
+		})
+		return
+	}
+	g.encryptTemplates = encrypt
+	if err := g.saveTemplates(); err != nil {
+		g.encryptTemplates = !encrypt
+		g.encryptTemplatesCheck.SetChecked(!encrypt)
+		dialog.ShowError(fmt.Errorf("Failed to save templates: %v", err), g.window)
+		return
+	}
+	if encrypt {
+		g.statusLabel.SetText("Templates are now encrypted.")
+	} else {
+		g.statusLabel.SetText("Templates are now stored in plaintext.")
+	}
+}
+
+// exportTemplates writes the templates as plaintext JSON to a file the
+// user picks, regardless of how the store itself is kept.
+func (g *GUI) exportTemplates() {
+	if g.templatesLocked {
+		dialog.ShowError(fmt.Errorf("Template store is locked"), g.window)
+		return
+	}
+	data, err := json.MarshalIndent(g.templates, "", "  ")
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	save := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+		defer zero(data)
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if w == nil {
+			return
+		}
+		defer w.Close()
+		if _, err := w.Write(data); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to export templates: %v", err), g.window)
+			return
+		}
+		g.statusLabel.SetText("Templates exported in plaintext to " + w.URI().Path())
+	}, g.window)
+	save.SetFileName(templateFile)
+	save.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	save.Show()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+const (
+	usenetRouteMail2News = "mail2news"
+	usenetRouteNNTP      = "nntp"
+)
+
+var usenetRoutes = []string{usenetRouteMail2News, usenetRouteNNTP}
+
+// newsgroupName matches a newsgroup name as defined by RFC 5536: dot
+// separated components of letters, digits, "+", "-" and "_".
+var newsgroupName = regexp.MustCompile(`^[A-Za-z0-9+_-]+(\.[A-Za-z0-9+_-]+)*$`)
+
+// messageIDToken matches a single <id@domain> message identifier.
+var messageIDToken = regexp.MustCompile(`^<[^<>\s@]+@[^<>\s@]+>$`)
+
+// parseNewsgroups validates a Newsgroups header value and returns the
+// groups it names.
+func parseNewsgroups(value string) ([]string, error) {
+	var groups []string
+	seen := make(map[string]bool)
+	for _, g := range strings.Split(value, ",") {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			return nil, fmt.Errorf("empty newsgroup name in %q", value)
+		}
+		if !newsgroupName.MatchString(g) {
+			return nil, fmt.Errorf("invalid newsgroup name %q", g)
+		}
+		for _, c := range strings.Split(g, ".") {
+			if strings.Trim(c, "0123456789") == "" || c == "all" || c == "ctl" {
+				return nil, fmt.Errorf("invalid newsgroup name %q", g)
+			}
+		}
+		if seen[strings.ToLower(g)] {
+			return nil, fmt.Errorf("newsgroup %q listed twice", g)
+		}
+		seen[strings.ToLower(g)] = true
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// checkArticle verifies that a CRLF message can be posted through a
+// mail2news gateway: exactly one valid Newsgroups header, a Subject and a
+// From, and well-formed References and Message-ID if present.
+func checkArticle(raw string) error {
+	header, _ := splitMessage(raw)
+	counts := make(map[string]int)
+	values := make(map[string]string)
+	for _, f := range headerFields(header) {
+		name := fieldName(f)
+		counts[name]++
+		values[name] = fieldValue(f)
+	}
+	for _, name := range []string{"Newsgroups", "Subject", "From"} {
+		key := strings.ToLower(name)
+		switch {
+		case counts[key] == 0 || values[key] == "":
+			return fmt.Errorf("missing %s header", name)
+		case counts[key] > 1:
+			return fmt.Errorf("more than one %s header", name)
+		}
+	}
+	if _, err := parseNewsgroups(values["newsgroups"]); err != nil {
+		return err
+	}
+	if !isValidEmail(values["from"]) {
+		return fmt.Errorf("invalid From address %q", values["from"])
+	}
+	if counts["references"] > 1 {
+		return fmt.Errorf("more than one References header")
+	}
+	for _, id := range strings.Fields(values["references"]) {
+		if !messageIDToken.MatchString(id) {
+			return fmt.Errorf("invalid message ID %q in References", id)
+		}
+	}
+	if id, ok := values["message-id"]; ok && !messageIDToken.MatchString(id) {
+		return fmt.Errorf("invalid Message-ID %q", id)
+	}
+	return nil
+}
+
+// gatewayList returns the non-empty lines of the gateway list, skipping
+// "#" comments.
+func gatewayList(text string) []string {
+	var gateways []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		gateways = append(gateways, line)
+	}
+	return gateways
+}
+
+// setRecipient replaces the To header of a CRLF message with to.
+func setRecipient(raw, to string) string {
+	header, body := splitMessage(raw)
+	fields := append([]string{"To: " + to}, removeFields(headerFields(header), "to")...)
+	return joinMessage(strings.Join(fields, "\r\n"), body)
+}
+
+// postUsenet sends the compose box as a Usenet article, either straight
+// to the profile's news server or through the configured mail2news
+// gateways. Articles are posted in the clear; PGP settings do not apply.
+func (g *GUI) postUsenet() {
+	if g.refuseAttachments("with a Usenet article") {
+		return
+	}
+	showError := func(err error) {
+		fyne.Do(func() {
+			dialog.ShowError(err, g.window)
+		})
+	}
+	text, err := g.resolveReplyBlocks(g.messageEnt.Text)
+	if err != nil {
+		showError(err)
+		return
+	}
+	raw := encodeHeaders(normalizeLineEndings(text))
+	if err := checkArticle(raw); err != nil {
+		showError(fmt.Errorf("Article rejected: %v", err))
+		return
+	}
+	if g.usenetRouteSelect.Selected == usenetRouteNNTP {
+		article, err := g.addAutoHeaders(raw)
+		if err != nil {
+			showError(err)
+			return
+		}
+		g.postNNTP(prepareBody(article, true))
+		return
+	}
+	g.postMail2News(raw)
+}
+
+// postMail2News mails a checked article to the mail2news gateways. Every
+// copy carries the same Message-ID, so news servers drop the duplicates
+// when several gateways are used.
+func (g *GUI) postMail2News(raw string) {
+	showError := func(err error) {
+		fyne.Do(func() {
+			dialog.ShowError(err, g.window)
+		})
+	}
+	gateways := gatewayList(g.mail2newsEntry.Text)
+	if len(gateways) == 0 {
+		showError(fmt.Errorf("No mail2news gateways configured"))
+		return
+	}
+	for _, gw := range gateways {
+		if !isValidEmail(gw) {
+			showError(fmt.Errorf("Invalid mail2news gateway %q", gw))
+			return
+		}
+	}
+	if !g.mail2newsAllCheck.Checked {
+		gateways = gateways[:1]
+	}
+
+	raw = setRecipient(raw, gateways[0])
+	from, _, article, err := g.prepareMessage(strings.ReplaceAll(raw, "\r\n", "\n"))
+	if err != nil {
+		showError(err)
+		return
+	}
+	// Lint once; the copies differ only in To.
+	g.checkHeaders(article, func() {
+		for _, gw := range gateways {
+			g.sendSMTP(from, gw, setRecipient(article, gw), nil)
+		}
+	})
+}